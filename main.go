@@ -2,26 +2,128 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
+	"math"
+	"math/bits"
+	"mime"
+	"mime/multipart"
+	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip13"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip96"
 	"github.com/zalando/go-keyring"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	appName       = "nos"
-	keyringUser   = "nos-cli"
-	keyringKey    = "nsec"
-	relayListKey  = "relay-list"
+	appName        = "nos"
+	keyringUser    = "nos-cli"
+	keyringKey     = "nsec"
+	relayListKey   = "relay-list"
+	relayAuthKey   = "relay-auth"
+	mediaServerKey = "media-server"
+	searchRelayKey = "search-relay"
+
+	// Searched in addition to any relay in the list that supports NIP-50,
+	// unless another search relay is configured
+	defaultSearchRelay = "wss://relay.nostr.band"
+
+	// Publishing timeouts: each relay gets its own connect and publish
+	// budget, and the whole fan-out is bounded by publishTimeout.
+	relayConnectTimeout = 10 * time.Second
+	relayPublishTimeout = 5 * time.Second
+	publishTimeout      = 15 * time.Second
+
+	// Upper bound for a whole broadcast, which may send many events
+	broadcastTimeout = 2 * time.Minute
+
+	// Upper bound for looking events up across relays
+	fetchTimeout = 10 * time.Second
+
+	// How long to wait before checking that relays honoured a deletion
+	deletionCheckDelay = 2 * time.Second
+
+	// Local data files and how often the scheduler checks its queue
+	scheduleFile         = "schedule.json"
+	schedulePollInterval = 30 * time.Second
+
+	// Outbox retries back off exponentially between these bounds
+	outboxFile      = "outbox.json"
+	outboxBaseDelay = time.Minute
+	outboxMaxDelay  = 6 * time.Hour
+
+	// Waiting for a publish quorum retries between these delays
+	quorumBaseDelay = 5 * time.Second
+	quorumMaxDelay  = time.Minute
+
+	draftsFile = "drafts.json"
+
+	// Default size of each note when splitting a thread
+	defaultThreadChars = 280
+
+	// Cached NIP-11 relay information documents; relays that don't serve
+	// one are asked again sooner
+	relayInfoFile       = "relay-info.json"
+	relayInfoTTL        = 24 * time.Hour
+	relayInfoFailureTTL = time.Hour
+	relayInfoTimeout    = 3 * time.Second
+
+	// In-run retries when a relay answers "rate-limited:"
+	rateLimitRetries   = 3
+	rateLimitBaseDelay = time.Second
+
+	// Uploading attachments, including any server-side processing
+	mediaUploadTimeout  = 2 * time.Minute
+	mediaProcessingPoll = 2 * time.Second
+
+	// Cached kind 0 profiles, used to show display names
+	profilesFile    = "profiles.json"
+	profileCacheTTL = 24 * time.Hour
+
+	// How many notes the feed shows by default, how long a live
+	// subscription waits before reconnecting to a relay, and how many
+	// authors go in one filter, since relays cap filter size
+	defaultFeedLimit     = 20
+	feedReconnectDelay   = 10 * time.Second
+	feedAuthorsPerFilter = 500
+
+	// How many parents nos read follows looking for a thread's root
+	maxThreadDepth = 50
+
+	// The notifications "last seen" cursor, how far back to look before
+	// there is one, and how far before it to look again for late events
+	notificationsFile    = "notifications.json"
+	notificationsSince   = 7 * 24 * time.Hour
+	notificationsOverlap = 10 * time.Minute
 )
 
 var (
@@ -41,6 +143,22 @@ var (
 			Foreground(lipgloss.Color("86"))
 )
 
+// Exit codes, so scripts can tell what went wrong
+const (
+	exitOK      = 0
+	exitFailed  = 1 // nothing was published, or the command failed
+	exitUsage   = 2 // invalid input
+	exitPartial = 3 // some relays accepted the event, others didn't
+	exitNoSetup = 4 // no key has been set up yet
+)
+
+// With --json, jsonMode is set and human-readable output is moved to
+// stderr, leaving jsonOutput (the real stdout) for JSON alone.
+var (
+	jsonMode   bool
+	jsonOutput io.Writer = os.Stdout
+)
+
 // Default relay list
 var defaultRelays = []string{
 	"wss://relay.damus.io",
@@ -52,46 +170,414 @@ var defaultRelays = []string{
 }
 
 func main() {
-	// Check if there's data available on stdin
-	stat, _ := os.Stdin.Stat()
-	hasStdinData := (stat.Mode() & os.ModeCharDevice) == 0
-
-	// If there's stdin data, read it and post
-	if hasStdinData {
-		scanner := bufio.NewScanner(os.Stdin)
-		var lines []string
-		for scanner.Scan() {
-			lines = append(lines, scanner.Text())
-		}
-		if err := scanner.Err(); err != nil && err != io.EOF {
-			fmt.Println(errorStyle.Render("Error reading from stdin: " + err.Error()))
-			os.Exit(1)
-		}
-		if len(lines) > 0 {
-			message := strings.Join(lines, "\n")
-			quickPost(message)
+	if i := slices.Index(os.Args, "--json"); i > 0 {
+		os.Args = slices.Delete(os.Args, i, i+1)
+		jsonMode = true
+		os.Stdout = os.Stderr
+	}
+
+	// Deliver anything earlier runs couldn't get to every relay before
+	// publishing more
+	if publishes(os.Args) {
+		retryDueOutbox()
+	}
+
+	// Commands take precedence over stdin so they can read it themselves
+	if len(os.Args) >= 2 {
+		switch os.Args[1] {
+		case "reset", "-reset":
+			handleReset()
+			return
+		case "relay", "-relay":
+			handleRelayCommand()
+			return
+		case "verify", "-verify":
+			os.Exit(handleVerify())
+		case "reply", "-reply":
+			handleReply()
+			return
+		case "repost", "-repost":
+			handleRepost()
+			return
+		case "quote", "-quote":
+			handleQuote()
+			return
+		case "react", "-react":
+			handleReact()
+			return
+		case "delete", "-delete":
+			handleDelete()
+			return
+		case "article", "-article":
+			handleArticleCommand()
+			return
+		case "schedule", "-schedule":
+			handleScheduleCommand()
+			return
+		case "outbox", "-outbox":
+			handleOutboxCommand()
+			return
+		case "post", "-post":
+			handlePost(os.Args[2:])
+			return
+		case "drafts", "-drafts":
+			handleDraftsCommand()
+			return
+		case "thread", "-thread":
+			handleThread()
+			return
+		case "media", "-media":
+			handleMediaCommand()
+			return
+		case "sign", "-sign":
+			handleSign()
+			return
+		case "publish", "-publish":
+			handlePublish()
+			return
+		case "event", "-event":
+			handleEvent()
+			return
+		case "broadcast", "-broadcast":
+			handleBroadcast()
+			return
+		case "feed", "-feed":
+			handleFeed()
+			return
+		case "read", "-read":
+			handleRead()
+			return
+		case "notifications", "-notifications":
+			handleNotifications()
+			return
+		case "search", "-search":
+			handleSearch()
 			return
 		}
 	}
 
-	// If no arguments, show interactive menu
-	if len(os.Args) < 2 {
+	// If no arguments and nothing piped in, show interactive menu
+	if len(os.Args) < 2 && !hasStdin() {
 		showMainMenu()
 		return
 	}
 
-	// Check for command-line commands (for backwards compatibility)
-	switch os.Args[1] {
-	case "reset", "-reset":
-		handleReset()
-	case "relay", "-relay":
-		handleRelayCommand()
-	case "verify", "-verify":
-		handleVerify()
-	default:
-		// Assume it's a message to post
-		quickPost(strings.Join(os.Args[1:], " "))
+	// Anything else is a message to post, possibly with flags
+	handlePost(os.Args[1:])
+}
+
+// publishes reports whether a command line publishes anything, deciding by
+// subcommand where a command has several. Dry runs stay offline.
+func publishes(args []string) bool {
+	if slices.Contains(args, "--dry-run") {
+		return false
+	}
+	if len(args) < 2 {
+		// The menu
+		return true
+	}
+	sub := ""
+	if len(args) >= 3 {
+		sub = args[2]
+	}
+	switch strings.TrimPrefix(args[1], "-") {
+	case "reset", "relay", "verify", "outbox", "media", "sign",
+		"feed", "read", "notifications", "search":
+		return false
+	case "schedule":
+		// Scheduling only queues the signed post; running the queue publishes it
+		return sub == "run"
+	case "drafts":
+		return sub == "post"
+	case "article":
+		return sub == "publish"
+	}
+	return true
+}
+
+// postOptions are the flags accepted when posting a note.
+type postOptions struct {
+	edit bool
+
+	// NIP-36 content warning, with an optional reason
+	contentWarning    bool
+	contentWarningMsg string
+
+	// NIP-40 expiration time, zero for none
+	expiresAt nostr.Timestamp
+
+	// NIP-13 proof of work difficulty; with powAuto nos mines whatever
+	// the relays ask for without prompting
+	powDifficulty int
+	powAuto       bool
+
+	// Print the signed event instead of publishing it
+	dryRun bool
+
+	// Files to upload, and the NIP-92 imeta tags of uploaded ones
+	attach []string
+	media  nostr.Tags
+
+	// A publish only succeeds once minRelays relays and every required
+	// relay accepted it; until retryUntil nos keeps trying the rest
+	minRelays  int
+	require    []string
+	retryUntil time.Time
+}
+
+// relays are the relays a post goes to: the active ones plus any required
+// relay that isn't among them.
+func (opts postOptions) relays() []string {
+	return mergeRelays(getActiveRelays(), opts.require)
+}
+
+// hasQuorum reports whether the post has a quorum to meet beyond a single
+// relay accepting it.
+func (opts postOptions) hasQuorum() bool {
+	return opts.minRelays > 0 || len(opts.require) > 0
+}
+
+// parsePostArgs separates posting flags from the words of the message.
+// Flags that take a value accept both "--flag value" and "--flag=value".
+func parsePostArgs(args []string) (postOptions, string, error) {
+	var opts postOptions
+	var words []string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "--edit", "-e":
+			opts.edit = true
+		case "--dry-run":
+			opts.dryRun = true
+		case "--cw":
+			reason, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			opts.contentWarning = true
+			opts.contentWarningMsg = reason
+		case "--expires":
+			value, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			opts.expiresAt, err = parseExpiration(value)
+			if err != nil {
+				return opts, "", err
+			}
+		case "--pow":
+			value, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			if value == "auto" {
+				opts.powAuto = true
+				continue
+			}
+			opts.powDifficulty, err = strconv.Atoi(value)
+			if err != nil || opts.powDifficulty < 0 || opts.powDifficulty > 256 {
+				return opts, "", fmt.Errorf("--pow must be a difficulty between 0 and 256, or auto")
+			}
+		case "--attach", "-a":
+			path, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			if _, err := os.Stat(path); err != nil {
+				return opts, "", fmt.Errorf("cannot attach %s: %v", path, err)
+			}
+			opts.attach = append(opts.attach, path)
+		case "--min-relays":
+			value, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			opts.minRelays, err = strconv.Atoi(value)
+			if err != nil || opts.minRelays < 1 {
+				return opts, "", fmt.Errorf("--min-relays must be a positive number")
+			}
+		case "--require":
+			url, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
+				return opts, "", fmt.Errorf("--require needs a relay URL starting with wss:// or ws://")
+			}
+			opts.require = append(opts.require, url)
+		case "--retry-until":
+			value, err := takeValue()
+			if err != nil {
+				return opts, "", err
+			}
+			opts.retryUntil, err = parseScheduleTime(value, time.Now())
+			if err != nil {
+				return opts, "", fmt.Errorf("invalid --retry-until: %v", err)
+			}
+			if !opts.retryUntil.After(time.Now()) {
+				return opts, "", fmt.Errorf("invalid --retry-until: %s is in the past", value)
+			}
+		default:
+			words = append(words, args[i])
+		}
+	}
+
+	if !opts.retryUntil.IsZero() && !opts.hasQuorum() {
+		return opts, "", fmt.Errorf("--retry-until needs --min-relays or --require")
+	}
+	// Attaching uploads the files, which a dry run mustn't do
+	if opts.dryRun && len(opts.attach) > 0 {
+		return opts, "", fmt.Errorf("--attach uploads files, so it can't be used with --dry-run")
+	}
+	if relays := opts.relays(); opts.minRelays > len(relays) {
+		return opts, "", fmt.Errorf("--min-relays %d is more than the %d relays you publish to", opts.minRelays, len(relays))
+	}
+	return opts, strings.Join(words, " "), nil
+}
+
+// parseExpiration turns "24h", "7d" or a date into a future timestamp.
+func parseExpiration(value string) (nostr.Timestamp, error) {
+	at, err := parseScheduleTime(value, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("invalid --expires: %v", err)
+	}
+	if !at.After(time.Now()) {
+		return 0, fmt.Errorf("invalid --expires: %s is in the past", value)
+	}
+	return nostr.Timestamp(at.Unix()), nil
+}
+
+// applyPostOptions adds the NIP-36, NIP-40 and NIP-92 tags requested in opts.
+func applyPostOptions(ev *nostr.Event, opts postOptions) {
+	if opts.contentWarning {
+		tag := nostr.Tag{"content-warning"}
+		if opts.contentWarningMsg != "" {
+			tag = append(tag, opts.contentWarningMsg)
+		}
+		ev.Tags = append(ev.Tags, tag)
+	}
+	if opts.expiresAt != 0 {
+		ev.Tags = append(ev.Tags, nostr.Tag{"expiration", strconv.FormatInt(int64(opts.expiresAt), 10)})
+	}
+	ev.Tags = append(ev.Tags, opts.media...)
+}
+
+// postOptionFields are the interactive equivalents of --cw, --expires and
+// --dry-run.
+func postOptionFields(cw *string, expires *string, publish *bool) []huh.Field {
+	return []huh.Field{
+		huh.NewInput().
+			Title("Content warning").
+			Description("Optional, e.g. \"spoilers\". Leave blank for none").
+			Value(cw),
+		huh.NewSelect[string]().
+			Title("Expires").
+			Options(
+				huh.NewOption("Never", ""),
+				huh.NewOption("In 1 hour", "1h"),
+				huh.NewOption("In 24 hours", "24h"),
+				huh.NewOption("In 7 days", "7d"),
+				huh.NewOption("In 30 days", "30d"),
+			).
+			Value(expires),
+		huh.NewConfirm().
+			Title("Publish now?").
+			Affirmative("Publish").
+			Negative("Dry run").
+			Description("A dry run only shows the signed event").
+			Value(publish),
+	}
+}
+
+// buildPostOptions turns the interactive option fields into postOptions.
+func buildPostOptions(cw string, expires string, publish bool) (postOptions, error) {
+	opts := postOptions{dryRun: !publish}
+	if cw = strings.TrimSpace(cw); cw != "" {
+		opts.contentWarning = true
+		opts.contentWarningMsg = cw
+	}
+	if expires != "" {
+		var err error
+		opts.expiresAt, err = parseExpiration(expires)
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// handlePost posts a message given on the command line, on stdin or written
+// in $EDITOR.
+func handlePost(args []string) {
+	opts, message, err := parsePostArgs(args)
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+
+	if message == "" && hasStdin() {
+		message, err = readStdin()
+		if err != nil {
+			fail(exitFailed, "reading from stdin: "+err.Error())
+		}
+	}
+
+	if opts.edit {
+		message, err = composeInEditor(message)
+		if err != nil {
+			fail(exitFailed, err.Error())
+		}
+		if message == "" {
+			fmt.Println(infoStyle.Render("Empty message, post cancelled."))
+			return
+		}
+	}
+
+	if strings.TrimSpace(message) == "" && len(opts.attach) == 0 {
+		if len(args) == 0 && !jsonMode {
+			showMainMenu()
+			return
+		}
+		if jsonMode {
+			fail(exitUsage, "no message to post")
+		}
+		showUsage()
+		os.Exit(exitUsage)
+	}
+
+	quickPost(message, opts)
+}
+
+// readStdin reads all lines from stdin and joins them back together.
+func readStdin() (string, error) {
+	scanner := bufio.NewScanner(os.Stdin)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// hasStdin reports whether data is being piped into nos.
+func hasStdin() bool {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false
 	}
+	return (stat.Mode() & os.ModeCharDevice) == 0
 }
 
 func showMainMenu() {
@@ -122,6 +608,9 @@ func showMainMenu() {
 		if hasKey {
 			options = []huh.Option[string]{
 				huh.NewOption("Post a message", "post"),
+				huh.NewOption("Write a post in your editor", "edit"),
+				huh.NewOption("Reply to a note", "reply"),
+				huh.NewOption("React to a note", "react"),
 				huh.NewOption("Verify your posts", "verify"),
 				huh.NewOption("Manage relays", "relay"),
 				huh.NewOption("Reset account", "reset"),
@@ -153,8 +642,16 @@ func showMainMenu() {
 			interactiveSetup()
 		case "post":
 			interactivePost()
+		case "edit":
+			interactiveEditorPost()
+		case "reply":
+			interactiveReply()
+		case "react":
+			interactiveReact()
 		case "verify":
-			handleVerify()
+			if handleVerify() == exitPartial {
+				fmt.Println(errorStyle.Render("Some relays couldn't be reached, so this may not be the full picture."))
+			}
 			fmt.Print("\nPress Enter to continue...")
 			fmt.Scanln()
 		case "relay":
@@ -171,9 +668,12 @@ func showMainMenu() {
 	}
 }
 
-func quickPost(message string) {
+func quickPost(message string, opts postOptions) {
 	// Try to get stored key
 	nsec, err := getStoredKey()
+	if err != nil && jsonMode {
+		fail(exitNoSetup, "no key set up yet, run 'nos' to set one up")
+	}
 	if err != nil {
 		// First time setup
 		fmt.Println(titleStyle.Render("Welcome to nos! 🚀"))
@@ -182,15 +682,13 @@ func quickPost(message string) {
 
 		nsec, err = promptForKey()
 		if err != nil {
-			fmt.Println(errorStyle.Render("Error: " + err.Error()))
-			os.Exit(1)
+			fail(exitNoSetup, err.Error())
 		}
 
 		// Store the key
 		err = storeKey(nsec)
 		if err != nil {
-			fmt.Println(errorStyle.Render("Error storing key: " + err.Error()))
-			os.Exit(1)
+			fail(exitFailed, "storing key: "+err.Error())
 		}
 
 		fmt.Println(successStyle.Render("✓ Key stored securely!"))
@@ -201,11 +699,27 @@ func quickPost(message string) {
 	var sk string
 	_, s, err := nip19.Decode(nsec)
 	if err != nil {
-		fmt.Println(errorStyle.Render("Error decoding key: " + err.Error()))
-		os.Exit(1)
+		fail(exitNoSetup, "decoding key: "+err.Error())
 	}
 	sk = s.(string)
 
+	if len(opts.attach) > 0 {
+		message, opts, err = attachMedia(sk, message, opts)
+		if err != nil {
+			fail(exitFailed, "uploading: "+err.Error())
+		}
+	}
+
+	// A dry run prints only the signed event, so it can be piped
+	if opts.dryRun {
+		ev, err := signPost(sk, message, opts)
+		if err != nil {
+			fail(exitFailed, "signing: "+err.Error())
+		}
+		printEventJSON(ev)
+		return
+	}
+
 	// Show public key for verification
 	pub, _ := nostr.GetPublicKey(sk)
 	npub, _ := nip19.EncodePublicKey(pub)
@@ -213,13 +727,42 @@ func quickPost(message string) {
 	
 	// Post to Nostr
 	fmt.Println(infoStyle.Render("Posting to Nostr..."))
-	err = postToNostr(sk, message)
+	ev, results, err := publishDraft(sk, "", message, opts)
+	if err != nil && ev.ID == "" {
+		// Nothing was signed, so there is nothing to report on
+		fail(exitFailed, "posting: "+err.Error())
+	}
+	if !opts.retryUntil.IsZero() && quorumShortfall(results, opts) != "" {
+		results = retryForQuorum(ev, results, opts)
+		if errors.Is(err, errNoRelayAccepted) && slices.ContainsFunc(results, func(res relayResult) bool { return res.err == nil }) {
+			err = nil
+		}
+	}
+	shortfall := ""
+	if opts.hasQuorum() {
+		shortfall = quorumShortfall(results, opts)
+	}
+
+	if jsonMode {
+		report := newPublishReport(ev, results, err)
+		if opts.hasQuorum() {
+			met := shortfall == ""
+			report.QuorumMet = &met
+			report.Shortfall = shortfall
+		}
+		printJSON(report)
+	}
 	if err != nil {
 		fmt.Println(errorStyle.Render("Error posting: " + err.Error()))
-		os.Exit(1)
+		os.Exit(exitFailed)
+	}
+	if shortfall != "" {
+		fmt.Println(errorStyle.Render("⚠ Posted, but the quorum wasn't met: " + shortfall))
+		os.Exit(exitPartial)
 	}
 
 	fmt.Println(successStyle.Render("✓ Posted successfully!"))
+	os.Exit(publishExitCode(results, err, opts))
 }
 
 func showUsage() {
@@ -231,8 +774,30 @@ func showUsage() {
 		fmt.Println(infoStyle.Render("  nos <message>              - Post a message to Nostr"))
 		fmt.Println(infoStyle.Render("  echo \"message\" | nos        - Post from stdin (good for hashtags/URLs)"))
 		fmt.Println(infoStyle.Render("  nos relay                  - Manage relay list"))
+		fmt.Println(infoStyle.Render("  nos reply <note> <message> - Reply to a note"))
+		fmt.Println(infoStyle.Render("  nos repost <note>          - Repost a note"))
+		fmt.Println(infoStyle.Render("  nos quote <note> <message> - Quote a note in a new post"))
+		fmt.Println(infoStyle.Render("  nos react <note> [emoji]   - React to a note (default +)"))
+		fmt.Println(infoStyle.Render("  nos delete <note>...       - Delete your own notes"))
+		fmt.Println(infoStyle.Render("  nos article publish <file> - Publish a Markdown article"))
+		fmt.Println(infoStyle.Render("  nos schedule <time> <msg>  - Schedule a post for later"))
+		fmt.Println(infoStyle.Render("  nos outbox status|flush    - Check or retry undelivered posts"))
+		fmt.Println(infoStyle.Render("  nos post --edit            - Write a post in $EDITOR"))
+		fmt.Println(infoStyle.Render("  nos drafts                 - Manage saved drafts"))
+		fmt.Println(infoStyle.Render("  nos thread [file]          - Split long text into a thread"))
+		fmt.Println(infoStyle.Render("  nos media set <url>        - Set the server for attachments"))
+		fmt.Println(infoStyle.Render("  nos sign | nos publish     - Sign offline, broadcast elsewhere"))
+		fmt.Println(infoStyle.Render("  nos event -k <kind> -t ... - Publish an event of any kind"))
+		fmt.Println(infoStyle.Render("  nos broadcast <note>...    - Copy events to relays missing them"))
+		fmt.Println(infoStyle.Render("  nos feed [--follow]        - Read recent posts from people you follow"))
+		fmt.Println(infoStyle.Render("  nos read <note>            - Read a note's whole thread"))
+		fmt.Println(infoStyle.Render("  nos notifications [--watch] - Show new replies, mentions, reactions and zaps"))
+		fmt.Println(infoStyle.Render("  nos search \"<query>\"       - Search notes on relays that support it"))
+		fmt.Println(infoStyle.Render("\nPost options: --cw <reason>, --expires <24h|7d|date>, --pow <n|auto>, --attach <file>, --edit, --dry-run"))
+		fmt.Println(infoStyle.Render("Publish quorum: --min-relays <n>, --require <url>, --retry-until <10m|date>"))
 		fmt.Println(infoStyle.Render("  nos verify                 - Check if your posts are on relays"))
 		fmt.Println(infoStyle.Render("  nos reset                  - Reset all data (change account)"))
+		fmt.Println(infoStyle.Render("\nAdd --json to post, verify, search or relay commands for machine-readable output."))
 		fmt.Println(infoStyle.Render("\nFirst time? Run 'nos' with a message to set up your key."))
 		fmt.Println(infoStyle.Render("\nTip: Use stdin for messages with special characters:"))
 		fmt.Println(infoStyle.Render("  echo \"Check out #bitcoin at https://bitcoin.org\" | nos"))
@@ -242,8 +807,30 @@ func showUsage() {
 		fmt.Println(infoStyle.Render("  nos <message>              - Post a message to Nostr"))
 		fmt.Println(infoStyle.Render("  echo \"message\" | nos        - Post from stdin (good for hashtags/URLs)"))
 		fmt.Println(infoStyle.Render("  nos relay                  - Manage relay list"))
+		fmt.Println(infoStyle.Render("  nos reply <note> <message> - Reply to a note"))
+		fmt.Println(infoStyle.Render("  nos repost <note>          - Repost a note"))
+		fmt.Println(infoStyle.Render("  nos quote <note> <message> - Quote a note in a new post"))
+		fmt.Println(infoStyle.Render("  nos react <note> [emoji]   - React to a note (default +)"))
+		fmt.Println(infoStyle.Render("  nos delete <note>...       - Delete your own notes"))
+		fmt.Println(infoStyle.Render("  nos article publish <file> - Publish a Markdown article"))
+		fmt.Println(infoStyle.Render("  nos schedule <time> <msg>  - Schedule a post for later"))
+		fmt.Println(infoStyle.Render("  nos outbox status|flush    - Check or retry undelivered posts"))
+		fmt.Println(infoStyle.Render("  nos post --edit            - Write a post in $EDITOR"))
+		fmt.Println(infoStyle.Render("  nos drafts                 - Manage saved drafts"))
+		fmt.Println(infoStyle.Render("  nos thread [file]          - Split long text into a thread"))
+		fmt.Println(infoStyle.Render("  nos media set <url>        - Set the server for attachments"))
+		fmt.Println(infoStyle.Render("  nos sign | nos publish     - Sign offline, broadcast elsewhere"))
+		fmt.Println(infoStyle.Render("  nos event -k <kind> -t ... - Publish an event of any kind"))
+		fmt.Println(infoStyle.Render("  nos broadcast <note>...    - Copy events to relays missing them"))
+		fmt.Println(infoStyle.Render("  nos feed [--follow]        - Read recent posts from people you follow"))
+		fmt.Println(infoStyle.Render("  nos read <note>            - Read a note's whole thread"))
+		fmt.Println(infoStyle.Render("  nos notifications [--watch] - Show new replies, mentions, reactions and zaps"))
+		fmt.Println(infoStyle.Render("  nos search \"<query>\"       - Search notes on relays that support it"))
+		fmt.Println(infoStyle.Render("\nPost options: --cw <reason>, --expires <24h|7d|date>, --pow <n|auto>, --attach <file>, --edit, --dry-run"))
+		fmt.Println(infoStyle.Render("Publish quorum: --min-relays <n>, --require <url>, --retry-until <10m|date>"))
 		fmt.Println(infoStyle.Render("  nos verify                 - Check if your posts are on relays"))
 		fmt.Println(infoStyle.Render("  nos reset                  - Reset all data (change account)"))
+		fmt.Println(infoStyle.Render("\nAdd --json to post, verify, search or relay commands for machine-readable output."))
 		fmt.Println(infoStyle.Render("\nTip: Use stdin for messages with special characters:"))
 		fmt.Println(infoStyle.Render("  echo \"Check out #bitcoin at https://bitcoin.org\" | nos"))
 	}
@@ -281,53 +868,114 @@ func handleReset() {
 
 	// Delete nsec key
 	err = keyring.Delete(appName, keyringUser)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
 		fmt.Println(errorStyle.Render("Error deleting key: " + err.Error()))
 	}
 
 	// Delete relay list
 	err = keyring.Delete(appName, relayListKey)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
 		fmt.Println(errorStyle.Render("Error deleting relay list: " + err.Error()))
 	}
 
+	// Delete relay login settings
+	err = keyring.Delete(appName, relayAuthKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("Error deleting relay login settings: " + err.Error()))
+	}
+
+	// Delete media server
+	err = keyring.Delete(appName, mediaServerKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("Error deleting media server: " + err.Error()))
+	}
+
+	// Delete search relay
+	err = keyring.Delete(appName, searchRelayKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("Error deleting search relay: " + err.Error()))
+	}
+
 	fmt.Println(successStyle.Render("✓ All data has been reset!"))
 	fmt.Println(infoStyle.Render("\nYou can now set up nos with a different account."))
 	fmt.Println(infoStyle.Render("Run 'nos <message>' to start fresh."))
 }
 
 func handleRelayCommand() {
-	// If a subcommand is provided, handle it directly (for backwards compatibility)
+	command := ""
 	if len(os.Args) >= 3 {
-		command := os.Args[2]
-		switch command {
-		case "list":
-			listRelays()
-			return
-		case "add":
-			if len(os.Args) < 4 {
-				showRelayMenu()
-				return
-			}
-			addRelay(os.Args[3])
-			return
-		case "remove":
-			if len(os.Args) < 4 {
-				showRelayMenu()
-				return
-			}
-			removeRelay(os.Args[3])
+		command = os.Args[2]
+	}
+
+	// JSON output has no interactive menu to fall back on
+	if jsonMode {
+		switch {
+		case command == "":
+			command = "list"
+		case (command == "add" || command == "remove") && len(os.Args) < 4:
+			fail(exitUsage, "missing relay URL")
+		}
+	}
+
+	// If a subcommand is provided, handle it directly (for backwards compatibility)
+	switch command {
+	case "list":
+		listRelays()
+	case "add":
+		if len(os.Args) < 4 {
+			showRelayMenu()
 			return
-		case "reset":
-			resetRelays()
+		}
+		addRelay(os.Args[3])
+	case "remove":
+		if len(os.Args) < 4 {
+			showRelayMenu()
 			return
 		}
+		removeRelay(os.Args[3])
+	case "reset":
+		resetRelays()
+	case "auth":
+		handleRelayAuth(os.Args[3:])
+	case "search":
+		handleSearchRelay(os.Args[3:])
+		return
+	default:
+		if jsonMode {
+			fail(exitUsage, "unknown relay command "+command)
+		}
+		// Show interactive menu
+		showRelayMenu()
+		return
 	}
-	
-	// Show interactive menu
-	showRelayMenu()
+
+	if jsonMode {
+		printJSON(newRelayListReport())
+	}
+}
+
+// relayListReport is the --json result of the relay commands.
+type relayListReport struct {
+	UsingDefaults bool         `json:"using_defaults"`
+	Relays        []relayEntry `json:"relays"`
+	SearchRelay   string       `json:"search_relay"`
+}
+
+type relayEntry struct {
+	URL  string `json:"url"`
+	Auth bool   `json:"auth"`
+}
+
+func newRelayListReport() relayListReport {
+	stored, _ := getStoredRelays()
+	report := relayListReport{UsingDefaults: len(stored) == 0, Relays: []relayEntry{}, SearchRelay: getSearchRelay()}
+	for _, url := range getActiveRelays() {
+		report.Relays = append(report.Relays, relayEntry{URL: url, Auth: authAllowed(url)})
+	}
+	return report
 }
 
+
 func showRelayMenu() {
 	for {
 		fmt.Println(titleStyle.Render("Relay Management"))
@@ -354,6 +1002,7 @@ func showRelayMenu() {
 						huh.NewOption("Add a relay", "add"),
 						huh.NewOption("Remove a relay", "remove"),
 						huh.NewOption("Reset to defaults", "reset"),
+						huh.NewOption("Choose relays to log in to", "auth"),
 						huh.NewOption("Back to main menu", "exit"),
 					).
 					Value(&choice),
@@ -378,6 +1027,8 @@ func showRelayMenu() {
 			interactiveRemoveRelay()
 		case "reset":
 			interactiveResetRelays()
+		case "auth":
+			interactiveRelayAuth()
 		case "exit":
 			return
 		}
@@ -391,6 +1042,8 @@ func showRelayUsage() {
 	fmt.Println(infoStyle.Render("  nos relay add <url>        - Add a relay"))
 	fmt.Println(infoStyle.Render("  nos relay remove <url>     - Remove a relay"))
 	fmt.Println(infoStyle.Render("  nos relay reset            - Reset to default relays"))
+	fmt.Println(infoStyle.Render("  nos relay auth <url> on|off - Allow logging in to a relay (NIP-42)"))
+	fmt.Println(infoStyle.Render("  nos relay search [url|default] - Show or set the relay nos search uses"))
 }
 
 func promptForKey() (string, error) {
@@ -438,656 +1091,6446 @@ func storeKey(nsec string) error {
 	return keyring.Set(appName, keyringUser, nsec)
 }
 
-func postToNostr(sk string, content string) error {
-	// Create event
-	pub, err := nostr.GetPublicKey(sk)
+// postToNostr signs and publishes a text note, returning the event and how
+// each relay answered.
+func postToNostr(sk string, content string, opts postOptions) (nostr.Event, []relayResult, error) {
+	ev, err := signPost(sk, content, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get public key: %v", err)
+		return ev, nil, err
 	}
-	
+
+	showEventDetails(ev)
+	results, err := publishEventResults(ev, opts.relays())
+	results, err = remineRejected(sk, ev, results, err, opts.powAuto)
+	return ev, results, err
+}
+
+// signPost builds and signs a text note, mining proof of work when asked
+// to or when the relays require it. It doesn't publish anything; dry runs
+// don't even look up the relays' requirements.
+func signPost(sk string, content string, opts postOptions) (nostr.Event, error) {
 	ev := nostr.Event{
-		PubKey:    pub,
-		CreatedAt: nostr.Now(),
-		Kind:      nostr.KindTextNote,
-		Tags:      []nostr.Tag{},
-		Content:   content,
+		Kind:    nostr.KindTextNote,
+		Tags:    extractContentTags(content),
+		Content: content,
 	}
+	applyPostOptions(&ev, opts)
 
-	// Calculate ID before signing
-	ev.ID = ev.GetID()
+	// Relays can advertise a minimum proof of work in their NIP-11 document
+	difficulty := opts.powDifficulty
+	if !opts.dryRun {
+		if required := relaysMinPow(opts.relays()); required > difficulty {
+			question := fmt.Sprintf("Some of your relays require proof of work of difficulty %d. Mine it?", required)
+			if confirmMining(question, opts.powAuto) {
+				difficulty = required
+			}
+		}
+	}
 
-	// Sign the event
-	err = ev.Sign(sk)
+	err := prepareEvent(sk, &ev)
 	if err != nil {
-		return fmt.Errorf("failed to sign event: %v", err)
+		return ev, err
 	}
-
-	// Verify the event is valid
-	ok, err := ev.CheckSignature()
-	if !ok || err != nil {
-		return fmt.Errorf("invalid event signature: %v", err)
+	if difficulty > 0 {
+		err = mineWithInterrupt(&ev, difficulty)
+		if err != nil {
+			return ev, err
+		}
 	}
 
-	// Show event details for verification
-	fmt.Println(infoStyle.Render("Event ID: " + ev.ID))
-	fmt.Println(infoStyle.Render("Created at: " + time.Unix(ev.CreatedAt.Time().Unix(), 0).Format(time.RFC3339)))
-	fmt.Println(infoStyle.Render("Content: " + content))
+	err = signEvent(sk, &ev)
+	return ev, err
+}
 
-	// Get relay list
-	relays := getActiveRelays()
-	fmt.Println(infoStyle.Render(fmt.Sprintf("Publishing to %d relays...", len(relays))))
+// printEventJSON writes a signed event to stdout as a single line of JSON.
+func printEventJSON(ev nostr.Event) error {
+	enc := json.NewEncoder(jsonOutput)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(ev)
+}
 
-	// Connect to relays and publish
-	successCount := 0
-	failedRelays := []string{}
-	
-	for _, url := range relays {
-		fmt.Printf("  %s Connecting to %s... ", infoStyle.Render("→"), url)
-		
-		// Create a timeout context for each connection
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		
-		relay, err := nostr.RelayConnect(ctx, url)
-		if err != nil {
-			fmt.Println(errorStyle.Render("failed: " + err.Error()))
-			failedRelays = append(failedRelays, fmt.Sprintf("%s (connection failed: %v)", url, err))
-			cancel()
-			continue
-		}
-		
-		// Publish with timeout
-		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err = relay.Publish(pubCtx, ev)
-		pubCancel()
-		
-		if err == nil {
-			fmt.Println(successStyle.Render("✓ published"))
-			successCount++
-		} else {
-			fmt.Println(errorStyle.Render("failed: " + err.Error()))
-			failedRelays = append(failedRelays, fmt.Sprintf("%s (publish failed: %v)", url, err))
-		}
-		
-		relay.Close()
-		cancel()
+// printJSON writes a --json result to stdout.
+func printJSON(v any) error {
+	enc := json.NewEncoder(jsonOutput)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// fail prints an error and exits with code. With --json the error is also
+// written to stdout.
+func fail(code int, msg string) {
+	os.Exit(reportFailure(code, msg))
+}
+
+// reportFailure is fail for code that runs from the interactive menu too:
+// it prints the error and returns code instead of exiting.
+func reportFailure(code int, msg string) int {
+	fmt.Println(errorStyle.Render("Error: " + msg))
+	if jsonMode {
+		printJSON(struct {
+			Error    string `json:"error"`
+			ExitCode int    `json:"exit_code"`
+		}{msg, code})
 	}
+	return code
+}
 
-	fmt.Println()
-	if successCount == 0 {
-		fmt.Println(errorStyle.Render("Failed relays:"))
-		for _, fr := range failedRelays {
-			fmt.Println(errorStyle.Render("  - " + fr))
-		}
-		return fmt.Errorf("failed to publish to any relay")
-	}
-
-	fmt.Println(successStyle.Render(fmt.Sprintf("Successfully published to %d/%d relays", successCount, len(relays))))
-	return nil
+// publishReport is the --json result of publishing an event.
+type publishReport struct {
+	EventID   string        `json:"event_id"`
+	Pubkey    string        `json:"pubkey"`
+	Kind      int           `json:"kind"`
+	CreatedAt int64         `json:"created_at"`
+	Accepted  int           `json:"accepted"`
+	Total     int           `json:"total"`
+	Relays    []relayReport `json:"relays"`
+	QuorumMet *bool         `json:"quorum_met,omitempty"`
+	Shortfall string        `json:"shortfall,omitempty"`
+	Error     string        `json:"error,omitempty"`
 }
 
-// Relay management functions
-func getActiveRelays() []string {
-	relays, err := getStoredRelays()
-	if err != nil || len(relays) == 0 {
-		return defaultRelays
-	}
-	return relays
+// relayReport is one relay's answer in a publishReport.
+type relayReport struct {
+	URL       string `json:"url"`
+	Status    string `json:"status"` // "ok", "duplicate" or "failed"
+	EventID   string `json:"event_id,omitempty"`
+	Stage     string `json:"stage,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+	ElapsedMs int64  `json:"elapsed_ms"`
 }
 
-func getStoredRelays() ([]string, error) {
-	data, err := keyring.Get(appName, relayListKey)
-	if err != nil {
-		return nil, err
+func newPublishReport(ev nostr.Event, results []relayResult, err error) publishReport {
+	report := publishReport{
+		EventID:   ev.ID,
+		Pubkey:    ev.PubKey,
+		Kind:      ev.Kind,
+		CreatedAt: int64(ev.CreatedAt),
+		Total:     len(results),
+		Relays:    []relayReport{},
 	}
-	
-	var relays []string
-	err = json.Unmarshal([]byte(data), &relays)
 	if err != nil {
-		return nil, err
+		report.Error = err.Error()
 	}
-	
-	return relays, nil
-}
 
-func storeRelays(relays []string) error {
-	data, err := json.Marshal(relays)
-	if err != nil {
-		return err
+	for _, res := range results {
+		relay := relayReport{URL: res.url, Status: "ok", EventID: res.eventID, ElapsedMs: res.elapsed.Milliseconds()}
+		switch {
+		case res.duplicate:
+			relay.Status = "duplicate"
+			report.Accepted++
+		case res.err == nil:
+			report.Accepted++
+		default:
+			relay.Status = "failed"
+			relay.Stage = res.stage
+			relay.Reason = relayPrefix(res.err)
+			relay.Error = res.describe()
+		}
+		report.Relays = append(report.Relays, relay)
 	}
-	
-	return keyring.Set(appName, relayListKey, string(data))
+	return report
 }
 
-func listRelays() {
-	relays := getActiveRelays()
-	usingDefaults := false
-	
-	// Check if we're using defaults
-	storedRelays, err := getStoredRelays()
-	if err != nil || len(storedRelays) == 0 {
-		usingDefaults = true
+// publishExitCode tells scripts whether an event reached every relay, only
+// some of them, or none. With a quorum, reaching it counts as success, but
+// an event no relay accepted has always failed.
+func publishExitCode(results []relayResult, err error, opts postOptions) int {
+	accepted := slices.ContainsFunc(results, func(res relayResult) bool {
+		return res.err == nil
+	})
+	if err != nil || !accepted {
+		return exitFailed
 	}
-	
-	fmt.Println(titleStyle.Render("Current Relay List"))
-	if usingDefaults {
-		fmt.Println(infoStyle.Render("(Using default relays)"))
+	if opts.hasQuorum() {
+		if quorumShortfall(results, opts) != "" {
+			return exitPartial
+		}
+		return exitOK
 	}
-	fmt.Println()
-	
-	for i, relay := range relays {
-		fmt.Printf("%s %d. %s\n", infoStyle.Render("•"), i+1, relay)
+	for _, res := range results {
+		if res.err != nil {
+			return exitPartial
+		}
 	}
+	return exitOK
 }
 
-func addRelay(url string) {
-	// Validate URL
-	if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
-		fmt.Println(errorStyle.Render("Error: Relay URL must start with wss:// or ws://"))
-		os.Exit(1)
-	}
-	
-	// Get current relays
-	relays, err := getStoredRelays()
-	if err != nil || len(relays) == 0 {
-		// If no custom relays, start with defaults
-		relays = make([]string, len(defaultRelays))
-		copy(relays, defaultRelays)
+// combineExitCodes merges the exit codes of several publishes. When they
+// all agree that's the result; otherwise some events got through and others
+// didn't, which is a partial success.
+func combineExitCodes(codes []int) int {
+	if len(codes) == 0 {
+		return exitOK
 	}
-	
-	// Check if already exists
-	for _, r := range relays {
-		if r == url {
-			fmt.Println(infoStyle.Render("Relay already in list: " + url))
-			return
+	for _, code := range codes[1:] {
+		if code != codes[0] {
+			return exitPartial
 		}
 	}
-	
-	// Add relay
-	relays = append(relays, url)
-	
-	// Store updated list
-	err = storeRelays(relays)
-	if err != nil {
-		fmt.Println(errorStyle.Render("Error storing relay list: " + err.Error()))
-		os.Exit(1)
-	}
-	
-	fmt.Println(successStyle.Render("✓ Added relay: " + url))
+	return codes[0]
 }
 
-func removeRelay(url string) {
-	// Get current relays
-	relays, err := getStoredRelays()
-	if err != nil || len(relays) == 0 {
-		fmt.Println(errorStyle.Render("Error: No custom relay list found. Use 'nos relay add' to create one."))
-		os.Exit(1)
-	}
-	
-	// Find and remove relay
-	found := false
-	newRelays := make([]string, 0)
-	for _, r := range relays {
-		if r != url {
-			newRelays = append(newRelays, r)
-		} else {
-			found = true
+// quorumShortfall explains how the results fall short of the quorum in
+// opts, or returns "" when it is met.
+func quorumShortfall(results []relayResult, opts postOptions) string {
+	accepted := make(map[string]bool)
+	for _, res := range results {
+		if res.err == nil {
+			accepted[nostr.NormalizeURL(res.url)] = true
 		}
 	}
-	
-	if !found {
-		fmt.Println(errorStyle.Render("Relay not found in list: " + url))
-		os.Exit(1)
-	}
-	
-	if len(newRelays) == 0 {
-		fmt.Println(errorStyle.Render("Error: Cannot remove all relays. Use 'nos relay reset' to restore defaults."))
-		os.Exit(1)
+
+	problems := []string{}
+	if len(accepted) < opts.minRelays {
+		problems = append(problems, fmt.Sprintf("%d relays accepted it, %d needed", len(accepted), opts.minRelays))
 	}
-	
-	// Store updated list
-	err = storeRelays(newRelays)
-	if err != nil {
-		fmt.Println(errorStyle.Render("Error storing relay list: " + err.Error()))
-		os.Exit(1)
+	for _, url := range opts.require {
+		if !accepted[nostr.NormalizeURL(url)] {
+			problems = append(problems, url+" didn't accept it")
+		}
 	}
-	
-	fmt.Println(successStyle.Render("✓ Removed relay: " + url))
+	return strings.Join(problems, "; ")
 }
 
-func resetRelays() {
-	// Remove stored relay list
-	err := keyring.Delete(appName, relayListKey)
-	if err != nil {
-		// Ignore error if key doesn't exist
-		if !strings.Contains(err.Error(), "not found") {
-			fmt.Println(errorStyle.Render("Error resetting relays: " + err.Error()))
-			os.Exit(1)
+// retryForQuorum keeps resending ev to the relays that haven't accepted it,
+// backing off between rounds, until the quorum in opts is met or
+// opts.retryUntil passes. Relays that refused the event outright aren't
+// tried again. It returns the results with the latest answers swapped in.
+func retryForQuorum(ev nostr.Event, results []relayResult, opts postOptions) []relayResult {
+	delivered := []string{}
+	delay := quorumBaseDelay
+	for {
+		shortfall := quorumShortfall(results, opts)
+		if shortfall == "" {
+			break
+		}
+		pending := []string{}
+		for _, res := range results {
+			if res.err != nil && shouldRetry(res.err) {
+				pending = append(pending, res.url)
+			}
+		}
+		wait := min(delay, time.Until(opts.retryUntil))
+		if len(pending) == 0 || wait <= 0 {
+			break
+		}
+
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Quorum not met (%s), retrying %d relays in %s...", shortfall, len(pending), wait.Round(time.Second))))
+		time.Sleep(wait)
+		for _, res := range broadcastEvent(ev, pending) {
+			for i := range results {
+				if results[i].url == res.url {
+					results[i] = res
+				}
+			}
+			if res.err == nil {
+				delivered = append(delivered, res.url)
+			}
 		}
+		delay = min(delay*2, quorumMaxDelay)
 	}
-	
-	fmt.Println(successStyle.Render("✓ Reset to default relays"))
-	listRelays()
+
+	// The outbox doesn't need to retry relays that got it in the meantime
+	if len(delivered) > 0 {
+		if err := outboxDelivered(ev.ID, delivered); err != nil {
+			fmt.Println(errorStyle.Render("Error updating outbox: " + err.Error()))
+		}
+	}
+	return results
 }
 
-func interactiveAddRelay() {
+// remineRejected offers to mine a fresh copy of ev for relays that rejected
+// it with "pow:" and sends it to just those relays. The copy has a new ID,
+// so relays that already accepted the original are left alone. Since that
+// puts two copies of the post on the network, it is never done without
+// asking once any relay has the original. It returns the results with
+// those relays' answers to the copy swapped in, and publishErr unless the
+// re-mined copy got through where nothing had before.
+func remineRejected(sk string, ev nostr.Event, results []relayResult, publishErr error, auto bool) ([]relayResult, error) {
+	required := 0
+	rejected := []string{}
+	for _, res := range results {
+		if d := powRequirement(res.err); d > 0 {
+			rejected = append(rejected, res.url)
+			required = max(required, d)
+		}
+	}
+	if len(rejected) == 0 {
+		return results, publishErr
+	}
+	if required <= nip13.CommittedDifficulty(&ev) {
+		// The relay's message didn't tell us anything we can act on
+		return results, publishErr
+	}
+
 	fmt.Println()
-	fmt.Println(titleStyle.Render("Add Relay"))
-	
-	var url string
-	form := huh.NewForm(
-		huh.NewGroup(
-			huh.NewInput().
-				Title("Enter relay URL").
-				Description("Must start with wss:// or ws://").
-				Placeholder("wss://relay.example.com").
-				Value(&url).
-				Validate(func(str string) error {
-					if str == "" {
-						return fmt.Errorf("URL cannot be empty")
-					}
-					if !strings.HasPrefix(str, "wss://") && !strings.HasPrefix(str, "ws://") {
-						return fmt.Errorf("URL must start with wss:// or ws://")
-					}
-					return nil
-				}),
-		),
-	)
+	question := fmt.Sprintf("%d relays want proof of work of difficulty %d. Mine and send it to them?", len(rejected), required)
+	if slices.ContainsFunc(results, func(res relayResult) bool { return res.err == nil }) {
+		if hasStdin() {
+			fmt.Println(infoStyle.Render(fmt.Sprintf("%d relays want proof of work of difficulty %d. Use --pow %d next time to mine before publishing.", len(rejected), required, required)))
+			return results, publishErr
+		}
+		question = fmt.Sprintf("%d relays want proof of work of difficulty %d. Other relays already have your post, so this would publish a second copy with a different ID and replies could be split between them. Send it anyway?", len(rejected), required)
+		auto = false
+	}
+	if !confirmMining(question, auto) {
+		return results, publishErr
+	}
 
-	err := form.Run()
+	mined := ev
+	mined.ID, mined.Sig = "", ""
+	err := mineWithInterrupt(&mined, required)
 	if err != nil {
-		fmt.Println(infoStyle.Render("Cancelled"))
-		return
+		return results, publishErr
 	}
-
-	// Get current relays
-	relays, err := getStoredRelays()
-	if err != nil || len(relays) == 0 {
-		// If no custom relays, start with defaults
-		relays = make([]string, len(defaultRelays))
-		copy(relays, defaultRelays)
+	err = signEvent(sk, &mined)
+	if err != nil {
+		return results, err
 	}
-	
-	// Check if already exists
-	for _, r := range relays {
-		if r == url {
-			fmt.Println(infoStyle.Render("\nRelay already in list: " + url))
-			fmt.Print("Press Enter to continue...")
-			fmt.Scanln()
-			return
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Publishing re-mined event %s to %d relays...", mined.ID, len(rejected))))
+	accepted := 0
+	failed := []relayResult{}
+	for _, res := range broadcastEvent(mined, rejected) {
+		res.eventID = mined.ID
+		for i := range results {
+			if results[i].url == res.url {
+				results[i] = res
+			}
+		}
+		if res.err == nil {
+			accepted++
+		} else if shouldRetry(res.err) {
+			failed = append(failed, res)
 		}
 	}
-	
-	// Add relay
-	relays = append(relays, url)
-	
-	// Store updated list
-	err = storeRelays(relays)
-	if err != nil {
-		fmt.Println(errorStyle.Render("\nError storing relay list: " + err.Error()))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+	if len(failed) > 0 {
+		queueOutbox(mined, failed, nil)
 	}
-	
-	fmt.Println(successStyle.Render("\n✓ Added relay: " + url))
-	fmt.Print("Press Enter to continue...")
-	fmt.Scanln()
-}
 
-func interactiveRemoveRelay() {
-	// Get current relays
-	relays, err := getStoredRelays()
-	if err != nil || len(relays) == 0 {
-		fmt.Println()
-		fmt.Println(errorStyle.Render("No custom relay list found."))
-		fmt.Println(infoStyle.Render("Using default relays. Add a custom relay first."))
-		fmt.Print("\nPress Enter to continue...")
-		fmt.Scanln()
-		return
+	if accepted > 0 && errors.Is(publishErr, errNoRelayAccepted) {
+		return results, nil
 	}
+	return results, publishErr
+}
 
-	fmt.Println()
-	fmt.Println(titleStyle.Render("Remove Relay"))
-	
-	// Create options for select
-	options := make([]huh.Option[string], len(relays))
-	for i, relay := range relays {
-		options[i] = huh.NewOption(relay, relay)
+// signAndPublish signs the event, shows its details and broadcasts it to
+// the active relays, returning how each relay answered.
+func signAndPublish(sk string, ev nostr.Event) ([]relayResult, error) {
+	err := signEvent(sk, &ev)
+	if err != nil {
+		return nil, err
 	}
-	
-	var selected string
-	form := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[string]().
-				Title("Select relay to remove").
-				Options(options...).
-				Value(&selected),
-		),
-	)
 
-	err = form.Run()
+	showEventDetails(ev)
+	return publishEventResults(ev, getActiveRelays())
+}
+
+// prepareEvent fills in the author and, unless already set, the timestamp.
+func prepareEvent(sk string, ev *nostr.Event) error {
+	pub, err := nostr.GetPublicKey(sk)
 	if err != nil {
-		fmt.Println(infoStyle.Render("Cancelled"))
-		return
+		return fmt.Errorf("failed to get public key: %v", err)
 	}
 
-	if len(relays) == 1 {
-		fmt.Println(errorStyle.Render("\nError: Cannot remove all relays."))
-		fmt.Println(infoStyle.Render("Use 'Reset to defaults' instead."))
-		fmt.Print("\nPress Enter to continue...")
-		fmt.Scanln()
-		return
+	ev.PubKey = pub
+	if ev.CreatedAt == 0 {
+		ev.CreatedAt = nostr.Now()
 	}
-
-	// Remove selected relay
-	newRelays := make([]string, 0)
-	for _, r := range relays {
-		if r != selected {
-			newRelays = append(newRelays, r)
-		}
+	if ev.Tags == nil {
+		ev.Tags = nostr.Tags{}
 	}
+	return nil
+}
 
-	// Store updated list
-	err = storeRelays(newRelays)
+// signEvent fills in the author and timestamp, then signs and verifies the
+// event in place.
+func signEvent(sk string, ev *nostr.Event) error {
+	err := prepareEvent(sk, ev)
 	if err != nil {
-		fmt.Println(errorStyle.Render("\nError storing relay list: " + err.Error()))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+		return err
 	}
-	
-	fmt.Println(successStyle.Render("\n✓ Removed relay: " + selected))
-	fmt.Print("Press Enter to continue...")
-	fmt.Scanln()
+
+	// Calculate ID before signing
+	ev.ID = ev.GetID()
+
+	// Sign the event
+	err = ev.Sign(sk)
+	if err != nil {
+		return fmt.Errorf("failed to sign event: %v", err)
+	}
+
+	// Verify the event is valid
+	ok, err := ev.CheckSignature()
+	if !ok || err != nil {
+		return fmt.Errorf("invalid event signature: %v", err)
+	}
+
+	return nil
 }
 
-func interactiveResetRelays() {
+// showEventDetails prints a signed event's ID, timestamp and content.
+func showEventDetails(ev nostr.Event) {
+	fmt.Println(infoStyle.Render("Event ID: " + ev.ID))
+	fmt.Println(infoStyle.Render("Created at: " + time.Unix(ev.CreatedAt.Time().Unix(), 0).Format(time.RFC3339)))
+	if ev.Kind != nostr.KindTextNote {
+		// Other kinds can carry long or structured content, so only preview it
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Kind: %d", ev.Kind)))
+		fmt.Println(infoStyle.Render("Content: " + truncate(ev.Content, 80)))
+	} else {
+		fmt.Println(infoStyle.Render("Content: " + ev.Content))
+	}
+	if len(ev.Tags) > 0 {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Tags: %d", len(ev.Tags))))
+	}
+}
+
+var (
+	// nostr: URIs as described in NIP-27
+	nostrURIRegex = regexp.MustCompile(`\bnostr:((?:npub|nprofile|note|nevent|naddr)1[02-9ac-hj-np-z]+)`)
+	urlRegex      = regexp.MustCompile(`\bhttps?://[^\s<>"]+`)
+	hashtagRegex  = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])#([\p{L}\p{N}_]*\p{L}[\p{L}\p{N}_]*)`)
+)
+
+// extractContentTags scans note content and builds the tags clients use to
+// index it: "t" for #hashtags, "r" for links and "p"/"q"/"a" for NIP-27
+// nostr: references. References carry the relay hint from their nevent,
+// nprofile or naddr, or else our first relay.
+func extractContentTags(content string) nostr.Tags {
+	tags := nostr.Tags{}
+	seen := make(map[string]bool)
+	add := func(tag nostr.Tag) {
+		key := tag[0] + ":" + tag[1]
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		tags = append(tags, tag)
+	}
+	fallback := ""
+	hint := func(relays []string) string {
+		if len(relays) > 0 {
+			return relays[0]
+		}
+		if fallback == "" {
+			fallback = firstRelay(getActiveRelays())
+		}
+		return fallback
+	}
+
+	// Mentions first, so they can be blanked out before looking for hashtags
+	for _, m := range nostrURIRegex.FindAllStringSubmatch(content, -1) {
+		prefix, data, err := nip19.Decode(m[1])
+		if err != nil {
+			continue
+		}
+		switch prefix {
+		case "npub":
+			add(nostr.Tag{"p", data.(string), hint(nil)})
+		case "nprofile":
+			pp := data.(nostr.ProfilePointer)
+			add(nostr.Tag{"p", pp.PublicKey, hint(pp.Relays)})
+		case "note":
+			add(nostr.Tag{"q", data.(string), hint(nil)})
+		case "nevent":
+			ep := data.(nostr.EventPointer)
+			q := nostr.Tag{"q", ep.ID, hint(ep.Relays)}
+			if ep.Author != "" {
+				q = append(q, ep.Author)
+				add(nostr.Tag{"p", ep.Author, hint(ep.Relays)})
+			}
+			add(q)
+		case "naddr":
+			ap := data.(nostr.EntityPointer)
+			add(nostr.Tag{"a", ap.AsTagReference(), hint(ap.Relays)})
+			add(nostr.Tag{"p", ap.PublicKey, hint(ap.Relays)})
+		}
+	}
+	stripped := nostrURIRegex.ReplaceAllString(content, " ")
+
+	for _, u := range urlRegex.FindAllString(stripped, -1) {
+		add(nostr.Tag{"r", trimURL(u)})
+	}
+	stripped = urlRegex.ReplaceAllString(stripped, " ")
+
+	for _, m := range hashtagRegex.FindAllStringSubmatch(stripped, -1) {
+		add(nostr.Tag{"t", strings.ToLower(m[1])})
+	}
+
+	return tags
+}
+
+// trimURL drops trailing punctuation that belongs to the sentence rather than
+// the link, keeping a closing parenthesis only when the URL opened one.
+func trimURL(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		if strings.ContainsRune(".,;:!?'\"", rune(last)) {
+			u = u[:len(u)-1]
+			continue
+		}
+		if last == ')' && strings.Count(u, "(") < strings.Count(u, ")") {
+			u = u[:len(u)-1]
+			continue
+		}
+		break
+	}
+	return u
+}
+
+// firstRelay returns the first relay hint from a list, or "" if there is none.
+func firstRelay(relays []string) string {
+	if len(relays) > 0 {
+		return relays[0]
+	}
+	return ""
+}
+
+// errNoRelayAccepted means a signed event reached no relay at all. The event
+// is still kept in the outbox for another try.
+var errNoRelayAccepted = errors.New("failed to publish to any relay")
+
+// relayResult is the outcome of publishing a single event to a single relay.
+type relayResult struct {
+	url     string
+	err     error
+	stage   string // "connection", "auth" or "publish"
+	elapsed time.Duration
+
+	// The relay already had the event, which counts as success
+	duplicate bool
+
+	// Set when a re-mined copy with a different ID was sent instead
+	eventID string
+}
+
+// describe explains in plain language why publishing to the relay failed.
+func (res relayResult) describe() string {
+	var re *relayError
+	if errors.As(res.err, &re) {
+		return explainRelayError(re)
+	}
+	return res.stage + " failed: " + res.err.Error()
+}
+
+// Machine-readable prefixes relays put on OK and CLOSED messages (NIP-01)
+const (
+	reasonDuplicate    = "duplicate"
+	reasonPow          = "pow"
+	reasonBlocked      = "blocked"
+	reasonRateLimited  = "rate-limited"
+	reasonInvalid      = "invalid"
+	reasonRestricted   = "restricted"
+	reasonAuthRequired = "auth-required"
+	reasonError        = "error"
+)
+
+// relayError is a relay refusing an event (OK false) or a subscription
+// (CLOSED), split into its NIP-01 prefix and the human-readable rest.
+type relayError struct {
+	prefix  string
+	message string
+}
+
+func (e *relayError) Error() string {
+	if e.prefix == "" {
+		return e.message
+	}
+	return e.prefix + ": " + e.message
+}
+
+// newRelayError parses the message of an OK or CLOSED. Messages without a
+// prefix are kept whole.
+func newRelayError(reason string) *relayError {
+	prefix, message, found := strings.Cut(reason, ":")
+	if !found || prefix == "" || strings.ContainsAny(prefix, " \t") {
+		return &relayError{message: strings.TrimSpace(reason)}
+	}
+	return &relayError{prefix: prefix, message: strings.TrimSpace(message)}
+}
+
+// classifyPublishError turns go-nostr's "msg: ..." errors for rejected
+// events into relayErrors. Timeouts and connection problems are returned
+// unchanged.
+func classifyPublishError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if reason, ok := strings.CutPrefix(err.Error(), "msg: "); ok {
+		return newRelayError(reason)
+	}
+	return err
+}
+
+// relayPrefix returns the NIP-01 prefix of a relay's refusal, or "" if err
+// isn't one.
+func relayPrefix(err error) string {
+	var re *relayError
+	if errors.As(err, &re) {
+		return re.prefix
+	}
+	return ""
+}
+
+// shouldRetry reports whether sending the event again later could work.
+// Relays that refused it on principle will just refuse it again.
+func shouldRetry(err error) bool {
+	switch relayPrefix(err) {
+	case reasonPow, reasonBlocked, reasonInvalid, reasonRestricted:
+		return false
+	}
+	return true
+}
+
+var relayReasonExplanations = map[string]string{
+	reasonDuplicate:    "already has this event",
+	reasonPow:          "requires proof of work",
+	reasonBlocked:      "has blocked you or this content",
+	reasonRateLimited:  "is rate limiting you, try again later",
+	reasonInvalid:      "rejected the event as invalid",
+	reasonRestricted:   "only accepts events from approved users",
+	reasonAuthRequired: "requires you to log in (NIP-42)",
+	reasonError:        "had an internal error",
+}
+
+// explainRelayError describes a relay's refusal in plain language, keeping
+// the relay's own words in brackets.
+func explainRelayError(re *relayError) string {
+	explanation, ok := relayReasonExplanations[re.prefix]
+	if !ok {
+		return "refused: " + re.Error()
+	}
+	if re.message == "" {
+		return explanation
+	}
+	return explanation + " (" + re.message + ")"
+}
+
+// publishEvent broadcasts a signed event to all active relays in parallel.
+// Progress is printed as each relay answers, followed by a summary. Relays
+// that failed are queued in the outbox for a later retry. It only returns an
+// error when no relay accepted the event.
+func publishEvent(ev nostr.Event) error {
+	_, err := publishEventResults(ev, getActiveRelays())
+	return err
+}
+
+// publishEventResults is publishEvent for the given relays, also returning
+// each relay's result.
+func publishEventResults(ev nostr.Event, relays []string) ([]relayResult, error) {
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Publishing to %d relays...", len(relays))))
+
+	results := broadcastEvent(ev, relays)
+
+	successCount := 0
+	duplicates := 0
+	failedRelays := []string{}
+	pending := []relayResult{}
+	for _, res := range results {
+		switch {
+		case res.err == nil:
+			successCount++
+			if res.duplicate {
+				duplicates++
+			}
+		default:
+			failedRelays = append(failedRelays, res.url+": "+res.describe())
+			if shouldRetry(res.err) {
+				pending = append(pending, res)
+			}
+		}
+	}
+
 	fmt.Println()
-	fmt.Println(titleStyle.Render("Reset Relays"))
-	fmt.Println(errorStyle.Render("This will restore the default relay list."))
+	if len(failedRelays) > 0 {
+		fmt.Println(errorStyle.Render("Failed relays:"))
+		for _, fr := range failedRelays {
+			fmt.Println(errorStyle.Render("  - " + fr))
+		}
+		showAuthHint(results)
+	}
+
+	if len(pending) > 0 {
+		// Relays outside the relay list were asked for explicitly, so the
+		// outbox keeps them even though they aren't active
+		if err := queueOutbox(ev, pending, inactiveRelays(relays)); err != nil {
+			fmt.Println(errorStyle.Render("Error saving to outbox: " + err.Error()))
+		} else {
+			fmt.Println(infoStyle.Render(fmt.Sprintf("Saved to the outbox, %d relays will be retried later ('nos outbox status').", len(pending))))
+		}
+	}
+
+	if successCount == 0 {
+		return results, errNoRelayAccepted
+	}
+
+	summary := fmt.Sprintf("Successfully published to %d/%d relays", successCount, len(relays))
+	if duplicates > 0 {
+		summary += fmt.Sprintf(" (%d already had it)", duplicates)
+	}
+	fmt.Println(successStyle.Render(summary))
+	return results, nil
+}
+
+// broadcastEvent publishes ev to the given relays in parallel under a single
+// deadline, printing progress as each relay answers.
+func broadcastEvent(ev nostr.Event, relays []string) []relayResult {
+	// One deadline for the whole fan-out so a dead relay can't hold up the rest
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	ch := make(chan relayResult, len(relays))
+	for _, url := range relays {
+		go func() {
+			ch <- publishToRelay(ctx, url, ev)
+		}()
+	}
+
+	results := make([]relayResult, 0, len(relays))
+	for i := range relays {
+		res := <-ch
+		progress := fmt.Sprintf("[%d/%d]", i+1, len(relays))
+		elapsed := res.elapsed.Round(time.Millisecond).String()
+
+		switch {
+		case res.duplicate:
+			fmt.Printf("  %s %s %s\n", infoStyle.Render(progress), res.url, successStyle.Render("✓ already had it ("+elapsed+")"))
+		case res.err == nil:
+			fmt.Printf("  %s %s %s\n", infoStyle.Render(progress), res.url, successStyle.Render("✓ published ("+elapsed+")"))
+		default:
+			fmt.Printf("  %s %s %s\n", infoStyle.Render(progress), res.url, errorStyle.Render(res.describe()))
+		}
+		results = append(results, res)
+	}
+
+	return results
+}
+
+// publishToRelay connects to a single relay and publishes the event to it,
+// giving up when ctx expires.
+func publishToRelay(ctx context.Context, url string, ev nostr.Event) relayResult {
+	start := time.Now()
+
+	connCtx, cancel := context.WithTimeout(ctx, relayConnectTimeout)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(connCtx, url)
+	if err != nil {
+		return relayResult{url: url, err: err, stage: "connection", elapsed: time.Since(start)}
+	}
+	defer relay.Close()
+
+	duplicate, stage, err := publishWithAuth(ctx, relay, url, ev)
+	return relayResult{url: url, err: err, stage: stage, elapsed: time.Since(start), duplicate: duplicate}
+}
+
+// publishWithAuth is publishWithBackoff that logs in with NIP-42 and tries
+// again when the relay asks for it. The stage tells which step failed.
+func publishWithAuth(ctx context.Context, relay *nostr.Relay, url string, ev nostr.Event) (duplicate bool, stage string, err error) {
+	duplicate, err = publishWithBackoff(ctx, relay, ev)
+	if relayPrefix(err) == reasonAuthRequired && authAllowed(url) {
+		if authErr := authenticateRelay(ctx, relay); authErr != nil {
+			return false, "auth", authErr
+		}
+		duplicate, err = publishWithBackoff(ctx, relay, ev)
+	}
+	return duplicate, "publish", err
+}
+
+// publishWithBackoff publishes ev on an open connection. A "duplicate:"
+// answer counts as success, and "rate-limited:" ones are retried a few
+// times with growing delays while ctx allows; after that the outbox takes
+// over.
+func publishWithBackoff(ctx context.Context, relay *nostr.Relay, ev nostr.Event) (duplicate bool, err error) {
+	delay := rateLimitBaseDelay
+	for attempt := 1; ; attempt++ {
+		pubCtx, cancel := context.WithTimeout(ctx, relayPublishTimeout)
+		err = classifyPublishError(relay.Publish(pubCtx, ev))
+		cancel()
+
+		switch relayPrefix(err) {
+		case reasonDuplicate:
+			return true, nil
+		case reasonRateLimited:
+			if attempt >= rateLimitRetries {
+				return false, err
+			}
+			select {
+			case <-time.After(delay):
+				delay *= 2
+				continue
+			case <-ctx.Done():
+			}
+		}
+		return false, err
+	}
+}
+
+// Relay management functions
+func getActiveRelays() []string {
+	relays, err := getStoredRelays()
+	if err != nil || len(relays) == 0 {
+		return defaultRelays
+	}
+	return relays
+}
+
+func getStoredRelays() ([]string, error) {
+	data, err := keyring.Get(appName, relayListKey)
+	if err != nil {
+		return nil, err
+	}
 	
-	var confirm bool
-	form := huh.NewForm(
-		huh.NewGroup(
-			huh.NewConfirm().
-				Title("Are you sure you want to reset to default relays?").
-				Affirmative("Yes, reset").
-				Negative("No, cancel").
-				Value(&confirm),
-		),
-	)
+	var relays []string
+	err = json.Unmarshal([]byte(data), &relays)
+	if err != nil {
+		return nil, err
+	}
+	
+	return relays, nil
+}
 
-	err := form.Run()
-	if err != nil || !confirm {
-		fmt.Println(infoStyle.Render("\nReset cancelled."))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+func storeRelays(relays []string) error {
+	data, err := json.Marshal(relays)
+	if err != nil {
+		return err
 	}
+	
+	return keyring.Set(appName, relayListKey, string(data))
+}
 
-	// Remove stored relay list
-	err = keyring.Delete(appName, relayListKey)
+// getAuthRelays returns the relays (by normalized URL) we are willing to
+// log in to with NIP-42.
+func getAuthRelays() []string {
+	data, err := keyring.Get(appName, relayAuthKey)
 	if err != nil {
-		// Ignore error if key doesn't exist
-		if !strings.Contains(err.Error(), "not found") {
-			fmt.Println(errorStyle.Render("\nError resetting relays: " + err.Error()))
-			fmt.Print("Press Enter to continue...")
-			fmt.Scanln()
+		return nil
+	}
+
+	var relays []string
+	if err := json.Unmarshal([]byte(data), &relays); err != nil {
+		return nil
+	}
+	return relays
+}
+
+func storeAuthRelays(relays []string) error {
+	data, err := json.Marshal(relays)
+	if err != nil {
+		return err
+	}
+
+	return keyring.Set(appName, relayAuthKey, string(data))
+}
+
+// authAllowed reports whether we may authenticate to the relay. Logging in
+// tells the relay who we are, so it is off unless enabled per relay.
+func authAllowed(url string) bool {
+	return slices.Contains(getAuthRelays(), nostr.NormalizeURL(url))
+}
+
+// authenticateRelay answers the relay's NIP-42 challenge with a kind 22242
+// event signed by the stored key.
+func authenticateRelay(ctx context.Context, relay *nostr.Relay) error {
+	sk, err := loadSecretKey()
+	if err != nil {
+		return err
+	}
+
+	authCtx, cancel := context.WithTimeout(ctx, relayPublishTimeout)
+	defer cancel()
+
+	err = relay.Auth(authCtx, func(ev *nostr.Event) error {
+		return ev.Sign(sk)
+	})
+	if err != nil {
+		return fmt.Errorf("login failed: %v", classifyPublishError(err))
+	}
+	return nil
+}
+
+// showAuthHint points out relays that want a login we aren't allowed to
+// give them.
+func showAuthHint(results []relayResult) {
+	for _, res := range results {
+		if relayPrefix(res.err) == reasonAuthRequired && !authAllowed(res.url) {
+			fmt.Println(infoStyle.Render(fmt.Sprintf("To log in to %s, run 'nos relay auth %s on'.", res.url, res.url)))
+		}
+	}
+}
+
+// handleRelayAuth lists or changes the relays we may log in to.
+func handleRelayAuth(args []string) {
+	if len(args) == 0 {
+		allowed := getAuthRelays()
+		if len(allowed) == 0 {
+			fmt.Println(infoStyle.Render("nos doesn't log in to any relay. Allow one with 'nos relay auth <url> on'."))
 			return
 		}
+		fmt.Println(titleStyle.Render("Relays nos may log in to"))
+		for _, url := range allowed {
+			fmt.Printf("%s %s\n", infoStyle.Render("•"), url)
+		}
+		return
+	}
+
+	url := args[0]
+	if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
+		fail(exitUsage, "relay URL must start with wss:// or ws://")
+	}
+	allow := true
+	if len(args) >= 2 {
+		switch args[1] {
+		case "on":
+		case "off":
+			allow = false
+		default:
+			showRelayUsage()
+			os.Exit(exitUsage)
+		}
+	}
+
+	if err := setRelayAuth(url, allow); err != nil {
+		fail(exitFailed, "storing relay login settings: "+err.Error())
+	}
+	if allow {
+		fmt.Println(successStyle.Render("✓ nos will log in to " + url + " when it asks"))
+	} else {
+		fmt.Println(successStyle.Render("✓ nos won't log in to " + url))
+	}
+}
+
+// setRelayAuth allows or forbids logging in to the relay.
+func setRelayAuth(url string, allow bool) error {
+	url = nostr.NormalizeURL(url)
+	relays := slices.DeleteFunc(getAuthRelays(), func(r string) bool {
+		return r == url
+	})
+	if allow {
+		relays = append(relays, url)
+	}
+	return storeAuthRelays(relays)
+}
+
+
+func listRelays() {
+	relays := getActiveRelays()
+	usingDefaults := false
+	
+	// Check if we're using defaults
+	storedRelays, err := getStoredRelays()
+	if err != nil || len(storedRelays) == 0 {
+		usingDefaults = true
+	}
+	
+	fmt.Println(titleStyle.Render("Current Relay List"))
+	if usingDefaults {
+		fmt.Println(infoStyle.Render("(Using default relays)"))
+	}
+	fmt.Println()
+	
+	for i, relay := range relays {
+		if authAllowed(relay) {
+			fmt.Printf("%s %d. %s %s\n", infoStyle.Render("•"), i+1, relay, infoStyle.Render("(login allowed)"))
+			continue
+		}
+		fmt.Printf("%s %d. %s\n", infoStyle.Render("•"), i+1, relay)
+	}
+}
+
+func addRelay(url string) {
+	// Validate URL
+	if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
+		fail(exitUsage, "relay URL must start with wss:// or ws://")
+	}
+	
+	// Get current relays
+	relays, err := getStoredRelays()
+	if err != nil || len(relays) == 0 {
+		// If no custom relays, start with defaults
+		relays = make([]string, len(defaultRelays))
+		copy(relays, defaultRelays)
+	}
+	
+	// Check if already exists
+	for _, r := range relays {
+		if r == url {
+			fmt.Println(infoStyle.Render("Relay already in list: " + url))
+			return
+		}
+	}
+	
+	// Add relay
+	relays = append(relays, url)
+	
+	// Store updated list
+	err = storeRelays(relays)
+	if err != nil {
+		fail(exitFailed, "storing relay list: "+err.Error())
+	}
+	
+	fmt.Println(successStyle.Render("✓ Added relay: " + url))
+	fmt.Println(infoStyle.Render("Copy your recent posts there with 'nos broadcast --since 30d'."))
+}
+
+func removeRelay(url string) {
+	// Get current relays
+	relays, err := getStoredRelays()
+	if err != nil || len(relays) == 0 {
+		fail(exitUsage, "no custom relay list found. Use 'nos relay add' to create one.")
+	}
+	
+	// Find and remove relay
+	found := false
+	newRelays := make([]string, 0)
+	for _, r := range relays {
+		if r != url {
+			newRelays = append(newRelays, r)
+		} else {
+			found = true
+		}
+	}
+	
+	if !found {
+		fail(exitUsage, "relay not found in list: "+url)
+	}
+	
+	if len(newRelays) == 0 {
+		fail(exitUsage, "cannot remove all relays. Use 'nos relay reset' to restore defaults.")
+	}
+	
+	// Store updated list
+	err = storeRelays(newRelays)
+	if err != nil {
+		fail(exitFailed, "storing relay list: "+err.Error())
+	}
+	
+	fmt.Println(successStyle.Render("✓ Removed relay: " + url))
+}
+
+func resetRelays() {
+	// Remove stored relay list
+	err := keyring.Delete(appName, relayListKey)
+	if err != nil {
+		// Ignore error if key doesn't exist
+		if !strings.Contains(err.Error(), "not found") {
+			fail(exitFailed, "resetting relays: "+err.Error())
+		}
+	}
+	
+	fmt.Println(successStyle.Render("✓ Reset to default relays"))
+	listRelays()
+}
+
+func interactiveAddRelay() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Add Relay"))
+	
+	var url string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Enter relay URL").
+				Description("Must start with wss:// or ws://").
+				Placeholder("wss://relay.example.com").
+				Value(&url).
+				Validate(func(str string) error {
+					if str == "" {
+						return fmt.Errorf("URL cannot be empty")
+					}
+					if !strings.HasPrefix(str, "wss://") && !strings.HasPrefix(str, "ws://") {
+						return fmt.Errorf("URL must start with wss:// or ws://")
+					}
+					return nil
+				}),
+		),
+	)
+
+	err := form.Run()
+	if err != nil {
+		fmt.Println(infoStyle.Render("Cancelled"))
+		return
+	}
+
+	// Get current relays
+	relays, err := getStoredRelays()
+	if err != nil || len(relays) == 0 {
+		// If no custom relays, start with defaults
+		relays = make([]string, len(defaultRelays))
+		copy(relays, defaultRelays)
+	}
+	
+	// Check if already exists
+	for _, r := range relays {
+		if r == url {
+			fmt.Println(infoStyle.Render("\nRelay already in list: " + url))
+			fmt.Print("Press Enter to continue...")
+			fmt.Scanln()
+			return
+		}
+	}
+	
+	// Add relay
+	relays = append(relays, url)
+	
+	// Store updated list
+	err = storeRelays(relays)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError storing relay list: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+	
+	fmt.Println(successStyle.Render("\n✓ Added relay: " + url))
+	fmt.Println(infoStyle.Render("Copy your recent posts there with 'nos broadcast --since 30d'."))
+	fmt.Print("Press Enter to continue...")
+	fmt.Scanln()
+}
+
+func interactiveRemoveRelay() {
+	// Get current relays
+	relays, err := getStoredRelays()
+	if err != nil || len(relays) == 0 {
+		fmt.Println()
+		fmt.Println(errorStyle.Render("No custom relay list found."))
+		fmt.Println(infoStyle.Render("Using default relays. Add a custom relay first."))
+		fmt.Print("\nPress Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Remove Relay"))
+	
+	// Create options for select
+	options := make([]huh.Option[string], len(relays))
+	for i, relay := range relays {
+		options[i] = huh.NewOption(relay, relay)
+	}
+	
+	var selected string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Select relay to remove").
+				Options(options...).
+				Value(&selected),
+		),
+	)
+
+	err = form.Run()
+	if err != nil {
+		fmt.Println(infoStyle.Render("Cancelled"))
+		return
+	}
+
+	if len(relays) == 1 {
+		fmt.Println(errorStyle.Render("\nError: Cannot remove all relays."))
+		fmt.Println(infoStyle.Render("Use 'Reset to defaults' instead."))
+		fmt.Print("\nPress Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Remove selected relay
+	newRelays := make([]string, 0)
+	for _, r := range relays {
+		if r != selected {
+			newRelays = append(newRelays, r)
+		}
+	}
+
+	// Store updated list
+	err = storeRelays(newRelays)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError storing relay list: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+	
+	fmt.Println(successStyle.Render("\n✓ Removed relay: " + selected))
+	fmt.Print("Press Enter to continue...")
+	fmt.Scanln()
+}
+
+func interactiveResetRelays() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Reset Relays"))
+	fmt.Println(errorStyle.Render("This will restore the default relay list."))
+	
+	var confirm bool
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Are you sure you want to reset to default relays?").
+				Affirmative("Yes, reset").
+				Negative("No, cancel").
+				Value(&confirm),
+		),
+	)
+
+	err := form.Run()
+	if err != nil || !confirm {
+		fmt.Println(infoStyle.Render("\nReset cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Remove stored relay list
+	err = keyring.Delete(appName, relayListKey)
+	if err != nil {
+		// Ignore error if key doesn't exist
+		if !strings.Contains(err.Error(), "not found") {
+			fmt.Println(errorStyle.Render("\nError resetting relays: " + err.Error()))
+			fmt.Print("Press Enter to continue...")
+			fmt.Scanln()
+			return
+		}
+	}
+	
+	fmt.Println(successStyle.Render("\n✓ Reset to default relays"))
+	fmt.Print("Press Enter to continue...")
+	fmt.Scanln()
+}
+func interactiveRelayAuth() {
+	relays := getActiveRelays()
+	allowed := []string{}
+	options := make([]huh.Option[string], len(relays))
+	for i, relay := range relays {
+		options[i] = huh.NewOption(relay, nostr.NormalizeURL(relay))
+		if authAllowed(relay) {
+			allowed = append(allowed, nostr.NormalizeURL(relay))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Relay Login (NIP-42)"))
+	fmt.Println(infoStyle.Render("Private and paid relays may ask who you are before accepting posts."))
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Which relays may nos log in to?").
+				Options(options...).
+				Value(&allowed),
+		),
+	)
+
+	err := form.Run()
+	if err != nil {
+		fmt.Println(infoStyle.Render("Cancelled"))
+		return
+	}
+
+	// Keep settings for relays that aren't in the current list
+	for _, url := range getAuthRelays() {
+		if !slices.ContainsFunc(relays, func(r string) bool { return nostr.NormalizeURL(r) == url }) {
+			allowed = append(allowed, url)
+		}
+	}
+
+	err = storeAuthRelays(allowed)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError storing relay login settings: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("\n✓ nos may log in to %d relays", len(allowed))))
+	fmt.Print("Press Enter to continue...")
+	fmt.Scanln()
+}
+
+// verifyReport is the --json result of nos verify.
+type verifyReport struct {
+	Pubkey string        `json:"pubkey"`
+	Npub   string        `json:"npub"`
+	Found  int           `json:"found"`
+	Relays []verifyRelay `json:"relays"`
+}
+
+// verifyRelay is what one relay returned for nos verify.
+type verifyRelay struct {
+	URL       string       `json:"url"`
+	Status    string       `json:"status"` // "found", "empty" or "failed"
+	Error     string       `json:"error,omitempty"`
+	ElapsedMs int64        `json:"elapsed_ms"`
+	Posts     []verifyPost `json:"posts,omitempty"`
+}
+
+type verifyPost struct {
+	ID        string `json:"id"`
+	Pubkey    string `json:"pubkey,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	Content   string `json:"content"`
+}
+
+// handleVerify checks the relays for our recent posts and returns the exit
+// code the CLI should use; the interactive menu ignores it.
+func handleVerify() int {
+	// Get stored key
+	nsec, err := getStoredKey()
+	if err != nil {
+		return reportFailure(exitNoSetup, "no stored key found, please set up nos first")
+	}
+
+	// Convert nsec to private key
+	var sk string
+	_, s, err := nip19.Decode(nsec)
+	if err != nil {
+		return reportFailure(exitNoSetup, "decoding key: "+err.Error())
+	}
+	sk = s.(string)
+
+	// Get public key
+	pub, _ := nostr.GetPublicKey(sk)
+	npub, _ := nip19.EncodePublicKey(pub)
+	
+	fmt.Println(titleStyle.Render("Verifying Posts"))
+	fmt.Println(infoStyle.Render("Your npub: " + npub))
+	fmt.Println(infoStyle.Render("Checking relays for your recent posts..."))
+	fmt.Println()
+
+	// Get relay list
+	relays := getActiveRelays()
+	postsFound := 0
+	report := verifyReport{Pubkey: pub, Npub: npub, Relays: []verifyRelay{}}
+	unreachable := 0
+
+	for _, url := range relays {
+		fmt.Printf("%s Checking %s... ", infoStyle.Render("→"), url)
+		start := time.Now()
+
+		// Create filter for user's posts
+		filter := nostr.Filter{
+			Authors: []string{pub},
+			Kinds:   []int{nostr.KindTextNote},
+			Limit:   5,
+		}
+
+		// queryRelay logs in first if the relay requires it and we allow it
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		events, err := queryRelay(ctx, url, filter)
+		cancel()
+
+		result := verifyRelay{URL: url, Status: "empty", ElapsedMs: time.Since(start).Milliseconds()}
+		var re *relayError
+		switch {
+		case len(events) == 0 && errors.As(err, &re):
+			fmt.Println(errorStyle.Render(explainRelayError(re)))
+			if re.prefix == reasonAuthRequired && !authAllowed(url) {
+				fmt.Println(infoStyle.Render(fmt.Sprintf("    Allow nos to log in with 'nos relay auth %s on'", url)))
+			}
+			result.Status, result.Error = "failed", re.Error()
+			report.Relays = append(report.Relays, result)
+			unreachable++
+			continue
+		case len(events) == 0 && err != nil:
+			fmt.Println(errorStyle.Render("connection failed"))
+			result.Status, result.Error = "failed", err.Error()
+			report.Relays = append(report.Relays, result)
+			unreachable++
+			continue
+		}
+
+		for _, ev := range events {
+			result.Posts = append(result.Posts, verifyPost{ID: ev.ID, CreatedAt: int64(ev.CreatedAt), Content: ev.Content})
+		}
+		if len(events) > 0 {
+			result.Status = "found"
+		}
+		report.Relays = append(report.Relays, result)
+
+		if len(events) > 0 {
+			fmt.Println(successStyle.Render(fmt.Sprintf("✓ found %d posts", len(events))))
+			postsFound += len(events)
+			
+			// Show recent posts from this relay
+			for i, ev := range events {
+				if i >= 3 { // Only show first 3
+					break
+				}
+				timestamp := time.Unix(ev.CreatedAt.Time().Unix(), 0).Format("2006-01-02 15:04:05")
+				content := ev.Content
+				if len(content) > 50 {
+					content = content[:50] + "..."
+				}
+				fmt.Printf("    %s [%s] %s\n", infoStyle.Render("•"), timestamp, content)
+			}
+		} else {
+			fmt.Println(infoStyle.Render("no posts found"))
+		}
+	}
+
+	fmt.Println()
+	if postsFound == 0 {
+		fmt.Println(errorStyle.Render("No posts found on any relay."))
+		fmt.Println(infoStyle.Render("This could mean:"))
+		fmt.Println(infoStyle.Render("  - Your posts haven't propagated yet (wait a few seconds)"))
+		fmt.Println(infoStyle.Render("  - The relays rejected your posts"))
+		fmt.Println(infoStyle.Render("  - There's an issue with your key"))
+	} else {
+	fmt.Println(successStyle.Render(fmt.Sprintf("Total posts found: %d", postsFound)))
+	}
+
+	report.Found = postsFound
+	if jsonMode {
+		printJSON(report)
+	}
+	switch {
+	case postsFound == 0:
+		return exitFailed
+	case unreachable > 0:
+		return exitPartial
+	}
+	return exitOK
+}
+
+func interactiveSetup() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Setup Account"))
+	fmt.Println(infoStyle.Render("Let's set up your Nostr account."))
+	fmt.Println()
+
+	nsec, err := promptForKey()
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nSetup cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Store the key
+	err = storeKey(nsec)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError storing key: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Show npub
+	var sk string
+	_, s, _ := nip19.Decode(nsec)
+	sk = s.(string)
+	pub, _ := nostr.GetPublicKey(sk)
+	npub, _ := nip19.EncodePublicKey(pub)
+
+	fmt.Println(successStyle.Render("\n✓ Account setup complete!"))
+	fmt.Println(infoStyle.Render("Your npub: " + npub))
+	fmt.Print("\nPress Enter to continue...")
+	fmt.Scanln()
+}
+
+func interactivePost() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Post to Nostr"))
+	
+	var message, cw, expires string
+	publish := true
+	fields := []huh.Field{
+		huh.NewText().
+			Title("What would you like to post?").
+			Description("Your message will be posted to Nostr").
+			Placeholder("Hello Nostr!").
+			Value(&message).
+			Validate(func(str string) error {
+				if strings.TrimSpace(str) == "" {
+					return fmt.Errorf("message cannot be empty")
+				}
+				return nil
+			}),
+	}
+	form := huh.NewForm(
+		huh.NewGroup(append(fields, postOptionFields(&cw, &expires, &publish)...)...),
+	)
+
+	err := form.Run()
+	if err != nil {
+		fmt.Println(infoStyle.Render("\nPost cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	opts, err := buildPostOptions(cw, expires, publish)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	interactivePublish("", message, opts)
+}
+
+// interactivePublish posts a message from the interactive menu, keeping it
+// as a draft until it has been published.
+func interactivePublish(draftID string, message string, opts postOptions) {
+	// Get stored key
+	nsec, err := getStoredKey()
+	if err != nil {
+		saveFailedDraft(draftID, message, opts)
+		fmt.Println(errorStyle.Render("\nError: No stored key found."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Convert nsec to private key
+	var sk string
+	_, s, err := nip19.Decode(nsec)
+	if err != nil {
+		saveFailedDraft(draftID, message, opts)
+		fmt.Println(errorStyle.Render("\nError decoding key: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+	sk = s.(string)
+
+	fmt.Println()
+	if opts.dryRun {
+		ev, err := signPost(sk, message, opts)
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error signing: " + err.Error()))
+		} else {
+			printEventJSON(ev)
+		}
+		saveFailedDraft(draftID, message, opts)
+		fmt.Print("\nPress Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Post to Nostr
+	_, _, err = publishDraft(sk, draftID, message, opts)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError posting: " + err.Error()))
+	} else {
+		fmt.Println(successStyle.Render("\n✓ Posted successfully!"))
+	}
+	
+	fmt.Print("\nPress Enter to continue...")
+	fmt.Scanln()
+}
+
+func interactiveEditorPost() {
+	message, err := composeInEditor("")
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+	if message == "" {
+		fmt.Println(infoStyle.Render("\nEmpty message, post cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	fmt.Println(titleStyle.Render("Post to Nostr"))
+	fmt.Println(message)
+	fmt.Println()
+
+	var cw, expires string
+	publish := true
+	form := huh.NewForm(huh.NewGroup(postOptionFields(&cw, &expires, &publish)...))
+	err = form.Run()
+	if err != nil {
+		saveFailedDraft("", message, postOptions{})
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	opts, err := buildPostOptions(cw, expires, publish)
+	if err != nil {
+		saveFailedDraft("", message, postOptions{})
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	interactivePublish("", message, opts)
+}
+
+func interactiveReset() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Reset Account"))
+	fmt.Println(errorStyle.Render("⚠️  This will delete your stored nsec key and relay configuration!"))
+	
+	var confirm bool
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Are you sure you want to reset all data?").
+				Affirmative("Yes, reset everything").
+				Negative("No, cancel").
+				Value(&confirm),
+		),
+	)
+
+	err := form.Run()
+	if err != nil || !confirm {
+		fmt.Println(infoStyle.Render("\nReset cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	// Delete nsec key
+	err = keyring.Delete(appName, keyringUser)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("\nError deleting key: " + err.Error()))
+	}
+
+	// Delete relay list
+	err = keyring.Delete(appName, relayListKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("\nError deleting relay list: " + err.Error()))
+	}
+
+	// Delete relay login settings
+	err = keyring.Delete(appName, relayAuthKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("\nError deleting relay login settings: " + err.Error()))
+	}
+
+	// Delete media server
+	err = keyring.Delete(appName, mediaServerKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("\nError deleting media server: " + err.Error()))
+	}
+
+	// Delete search relay
+	err = keyring.Delete(appName, searchRelayKey)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Println(errorStyle.Render("\nError deleting search relay: " + err.Error()))
+	}
+
+	fmt.Println(successStyle.Render("\n✓ All data has been reset!"))
+	fmt.Println(infoStyle.Render("You can now set up nos with a different account."))
+	fmt.Print("\nPress Enter to continue...")
+	fmt.Scanln()
+}
+
+// loadSecretKey returns the stored private key in hex form.
+func loadSecretKey() (string, error) {
+	nsec, err := getStoredKey()
+	if err != nil {
+		return "", fmt.Errorf("no stored key found, please set up nos first")
+	}
+
+	_, s, err := nip19.Decode(nsec)
+	if err != nil {
+		return "", fmt.Errorf("error decoding key: %v", err)
+	}
+	return s.(string), nil
+}
+
+// parseEventRef accepts a note1, nevent1 or hex event ID, with or without a
+// nostr: prefix, and returns a pointer to the event.
+func parseEventRef(ref string) (nostr.EventPointer, error) {
+	ref = strings.TrimPrefix(strings.TrimSpace(ref), "nostr:")
+
+	if nostr.IsValid32ByteHex(ref) {
+		return nostr.EventPointer{ID: ref}, nil
+	}
+
+	prefix, data, err := nip19.Decode(ref)
+	if err != nil {
+		return nostr.EventPointer{}, fmt.Errorf("invalid event reference %q", ref)
+	}
+
+	switch prefix {
+	case "note":
+		return nostr.EventPointer{ID: data.(string)}, nil
+	case "nevent":
+		return data.(nostr.EventPointer), nil
+	default:
+		return nostr.EventPointer{}, fmt.Errorf("expected a note1, nevent1 or hex event ID, got %s", prefix)
+	}
+}
+
+// parseRef is like parseEventRef but also accepts naddr1 addresses.
+func parseRef(ref string) (nostr.Pointer, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(ref), "nostr:")
+	if strings.HasPrefix(trimmed, "naddr1") {
+		prefix, data, err := nip19.Decode(trimmed)
+		if err != nil || prefix != "naddr" {
+			return nil, fmt.Errorf("invalid event reference %q", trimmed)
+		}
+		return data.(nostr.EntityPointer), nil
+	}
+	return parseEventRef(ref)
+}
+
+// mergeRelays combines relay lists in order, dropping duplicates and
+// anything that isn't a relay URL.
+func mergeRelays(lists ...[]string) []string {
+	merged := []string{}
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, url := range list {
+			if !nostr.IsValidRelayURL(url) {
+				continue
+			}
+			normalized := nostr.NormalizeURL(url)
+			if seen[normalized] {
+				continue
+			}
+			seen[normalized] = true
+			merged = append(merged, url)
+		}
+	}
+	return merged
+}
+
+// queryRelay connects to a single relay and returns the events it stores
+// for the filters.
+func queryRelay(ctx context.Context, url string, filters ...nostr.Filter) ([]*nostr.Event, error) {
+	connCtx, cancel := context.WithTimeout(ctx, relayConnectTimeout)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(connCtx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer relay.Close()
+
+	var events []*nostr.Event
+	authenticated := false
+	for _, filter := range filters {
+		sub, err := relay.Subscribe(ctx, nostr.Filters{filter})
+		if err != nil {
+			return events, err
+		}
+		found, err := collectStored(ctx, sub)
+
+		// Log in and ask again if the relay wants to know who we are
+		if relayPrefix(err) == reasonAuthRequired && !authenticated && authAllowed(url) {
+			if authErr := authenticateRelay(ctx, relay); authErr != nil {
+				return events, authErr
+			}
+			authenticated = true
+			if sub, err = relay.Subscribe(ctx, nostr.Filters{filter}); err != nil {
+				return events, err
+			}
+			found, err = collectStored(ctx, sub)
+		}
+
+		events = append(events, found...)
+		if err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
+
+// collectStored gathers a subscription's stored events until EOSE. A
+// CLOSED from the relay is returned as a relayError; running out of time
+// just ends the results early.
+func collectStored(ctx context.Context, sub *nostr.Subscription) ([]*nostr.Event, error) {
+	defer sub.Unsub()
+
+	var events []*nostr.Event
+	for {
+		select {
+		case ev, ok := <-sub.Events:
+			if !ok {
+				return events, nil
+			}
+			events = append(events, ev)
+		case <-sub.EndOfStoredEvents:
+			return events, nil
+		case reason := <-sub.ClosedReason:
+			return events, newRelayError(reason)
+		case <-ctx.Done():
+			return events, nil
+		}
+	}
+}
+
+// fetchEvent looks an event or addressable event up on its relay hints and
+// the active relays in parallel, returning a valid copy along with the relay
+// it came from. For addresses the newest version wins.
+func fetchEvent(ptr nostr.Pointer) (*nostr.Event, string, error) {
+	var hints []string
+	_, addressable := ptr.(nostr.EntityPointer)
+	switch p := ptr.(type) {
+	case nostr.EventPointer:
+		hints = p.Relays
+	case nostr.EntityPointer:
+		hints = p.Relays
+	}
+	relays := mergeRelays(hints, getActiveRelays())
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	type fetchResult struct {
+		ev  *nostr.Event
+		url string
+	}
+
+	results := make(chan fetchResult, len(relays))
+	for _, url := range relays {
+		go func() {
+			var best *nostr.Event
+			events, _ := queryRelay(ctx, url, ptr.AsFilter())
+			for _, ev := range events {
+				if !ptr.MatchesEvent(*ev) {
+					continue
+				}
+				if ok, _ := ev.CheckSignature(); !ok {
+					continue
+				}
+				if best == nil || ev.CreatedAt > best.CreatedAt {
+					best = ev
+				}
+			}
+			results <- fetchResult{best, url}
+		}()
+	}
+
+	var best *nostr.Event
+	var bestURL string
+	for range relays {
+		res := <-results
+		if res.ev == nil {
+			continue
+		}
+		if !addressable {
+			return res.ev, res.url, nil
+		}
+		if best == nil || res.ev.CreatedAt > best.CreatedAt {
+			best, bestURL = res.ev, res.url
+		}
+	}
+	if best != nil {
+		return best, bestURL, nil
+	}
+
+	return nil, "", fmt.Errorf("event %s not found on any relay", ptr.AsTagReference())
+}
+
+// appendUniqueTags adds tags that aren't already present, comparing by tag
+// name and value.
+func appendUniqueTags(tags nostr.Tags, extra nostr.Tags) nostr.Tags {
+	for _, tag := range extra {
+		if len(tag) < 2 || tags.FindWithValue(tag[0], tag[1]) != nil {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// threadRoot returns the root of the thread the tags belong to per NIP-10:
+// the e tag marked "root", or else the first e tag that isn't a mention.
+func threadRoot(tags nostr.Tags) (nostr.EventPointer, bool) {
+	var fallback nostr.Tag
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[0] != "e" || !nostr.IsValid32ByteHex(tag[1]) {
+			continue
+		}
+		marker := ""
+		if len(tag) >= 4 {
+			marker = tag[3]
+		}
+		if marker == "root" {
+			fallback = tag
+			break
+		}
+		if marker != "mention" && fallback == nil {
+			fallback = tag
+		}
+	}
+	if fallback == nil {
+		return nostr.EventPointer{}, false
+	}
+	root, err := nostr.EventPointerFromTag(fallback)
+	return root, err == nil && root.ID != ""
+}
+
+// buildReplyTags returns the NIP-10 tags for a reply to parent, which was
+// found on relayURL: marked "root" and "reply" e tags, the parent author and
+// every p tag already in the thread.
+func buildReplyTags(parent *nostr.Event, relayURL string, selfPub string) nostr.Tags {
+	tags := nostr.Tags{}
+
+	if root, ok := threadRoot(parent.Tags); ok && root.ID != parent.ID {
+		rootTag := nostr.Tag{"e", root.ID, firstRelay(root.Relays), "root"}
+		if root.Author != "" {
+			rootTag = append(rootTag, root.Author)
+		}
+		tags = append(tags, rootTag)
+		tags = append(tags, nostr.Tag{"e", parent.ID, relayURL, "reply", parent.PubKey})
+	} else {
+		// Replying to a top-level note makes it the root
+		tags = append(tags, nostr.Tag{"e", parent.ID, relayURL, "root", parent.PubKey})
+	}
+
+	pTags := nostr.Tags{{"p", parent.PubKey}}
+	for _, tag := range parent.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && nostr.IsValidPublicKey(tag[1]) {
+			pTags = append(pTags, nostr.Tag{"p", tag[1]})
+		}
+	}
+	for _, tag := range pTags {
+		if tag[1] != selfPub {
+			tags = appendUniqueTags(tags, nostr.Tags{tag})
+		}
+	}
+
+	return tags
+}
+
+// postReply publishes a kind 1 reply to parent, threaded per NIP-10.
+func postReply(sk string, parent *nostr.Event, relayURL string, content string) ([]relayResult, error) {
+	pub, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key: %v", err)
+	}
+
+	tags := buildReplyTags(parent, relayURL, pub)
+	tags = appendUniqueTags(tags, extractContentTags(content))
+
+	ev := nostr.Event{
+		Kind:    nostr.KindTextNote,
+		Tags:    tags,
+		Content: content,
+	}
+
+	return signAndPublish(sk, ev)
+}
+
+// truncate shortens s to at most n characters for one-line previews.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return s
+}
+
+// showEventPreview prints the author and a short excerpt of an event.
+func showEventPreview(ev *nostr.Event) {
+	npub, _ := nip19.EncodePublicKey(ev.PubKey)
+	timestamp := time.Unix(ev.CreatedAt.Time().Unix(), 0).Format("2006-01-02 15:04:05")
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Note by %s... [%s]", npub[:16], timestamp)))
+	fmt.Printf("    %s %s\n", infoStyle.Render("•"), truncate(ev.Content, 80))
+}
+
+func handleReply() {
+	if len(os.Args) < 3 {
+		fail(exitUsage, "usage: nos reply <note1|nevent1|hex-id> <message>")
+	}
+
+	ptr, err := parseEventRef(os.Args[2])
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+
+	message := strings.Join(os.Args[3:], " ")
+	if message == "" && hasStdin() {
+		message, err = readStdin()
+		if err != nil {
+			fail(exitFailed, "reading from stdin: "+err.Error())
+		}
+	}
+	if strings.TrimSpace(message) == "" {
+		fail(exitUsage, "Please provide a reply message")
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	parent, relayURL, err := fetchEvent(ptr)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+	showEventPreview(parent)
+	fmt.Println()
+
+	fmt.Println(infoStyle.Render("Posting reply..."))
+	results, err := postReply(sk, parent, relayURL, message)
+	if err != nil {
+		fail(exitFailed, "posting: "+err.Error())
+	}
+
+	fmt.Println(successStyle.Render("✓ Reply posted successfully!"))
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+func interactiveReply() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Reply to a Note"))
+
+	var ref, message string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Which note are you replying to?").
+				Description("note1, nevent1 or hex event ID").
+				Placeholder("note1...").
+				Value(&ref).
+				Validate(func(str string) error {
+					_, err := parseEventRef(str)
+					return err
+				}),
+			huh.NewText().
+				Title("Your reply").
+				Placeholder("Great point!").
+				Value(&message).
+				Validate(func(str string) error {
+					if strings.TrimSpace(str) == "" {
+						return fmt.Errorf("reply cannot be empty")
+					}
+					return nil
+				}),
+		),
+	)
+
+	err := form.Run()
+	if err != nil {
+		fmt.Println(infoStyle.Render("\nReply cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	ptr, _ := parseEventRef(ref)
+	fmt.Println()
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	parent, relayURL, err := fetchEvent(ptr)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+	showEventPreview(parent)
+	fmt.Println()
+
+	_, err = postReply(sk, parent, relayURL, message)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError posting: " + err.Error()))
+	} else {
+		fmt.Println(successStyle.Render("\n✓ Reply posted successfully!"))
+	}
+
+	fmt.Print("\nPress Enter to continue...")
+	fmt.Scanln()
+}
+
+// postRepost publishes a NIP-18 repost of target: kind 6 for text notes and a
+// generic kind 16 repost for everything else, with the original embedded.
+func postRepost(sk string, target *nostr.Event, relayURL string) ([]relayResult, error) {
+	raw, err := json.Marshal(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reposted event: %v", err)
+	}
+
+	ev := nostr.Event{
+		Kind: nostr.KindRepost,
+		Tags: nostr.Tags{
+			{"e", target.ID, relayURL},
+			{"p", target.PubKey},
+		},
+		Content: string(raw),
+	}
+
+	if target.Kind != nostr.KindTextNote {
+		ev.Kind = nostr.KindGenericRepost
+		ev.Tags = append(ev.Tags, nostr.Tag{"k", strconv.Itoa(target.Kind)})
+		if nostr.IsReplaceableKind(target.Kind) || nostr.IsAddressableKind(target.Kind) {
+			addr := nostr.EntityPointer{PublicKey: target.PubKey, Kind: target.Kind, Identifier: target.Tags.GetD()}
+			ev.Tags = append(ev.Tags, nostr.Tag{"a", addr.AsTagReference(), relayURL})
+		}
+	}
+
+	return signAndPublish(sk, ev)
+}
+
+// postQuote publishes a kind 1 note that quotes target, appending a
+// nostr:nevent reference to the content and a NIP-18 "q" tag.
+func postQuote(sk string, target *nostr.Event, relayURL string, content string) ([]relayResult, error) {
+	nevent, err := nip19.EncodeEvent(target.ID, []string{relayURL}, target.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode quoted event: %v", err)
+	}
+	content = strings.TrimRight(content, "\n") + "\n\nnostr:" + nevent
+
+	tags := nostr.Tags{
+		{"q", target.ID, relayURL, target.PubKey},
+		{"p", target.PubKey},
+	}
+	tags = appendUniqueTags(tags, extractContentTags(content))
+
+	ev := nostr.Event{
+		Kind:    nostr.KindTextNote,
+		Tags:    tags,
+		Content: content,
+	}
+
+	return signAndPublish(sk, ev)
+}
+
+func handleRepost() {
+	if len(os.Args) < 3 {
+		fail(exitUsage, "usage: nos repost <note1|nevent1|hex-id>")
+	}
+
+	ptr, err := parseEventRef(os.Args[2])
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	target, relayURL, err := fetchEvent(ptr)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+	showEventPreview(target)
+	fmt.Println()
+
+	fmt.Println(infoStyle.Render("Reposting..."))
+	results, err := postRepost(sk, target, relayURL)
+	if err != nil {
+		fail(exitFailed, "reposting: "+err.Error())
+	}
+
+	fmt.Println(successStyle.Render("✓ Reposted successfully!"))
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+func handleQuote() {
+	if len(os.Args) < 3 {
+		fail(exitUsage, "usage: nos quote <note1|nevent1|hex-id> <message>")
+	}
+
+	ptr, err := parseEventRef(os.Args[2])
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+
+	message := strings.Join(os.Args[3:], " ")
+	if message == "" && hasStdin() {
+		message, err = readStdin()
+		if err != nil {
+			fail(exitFailed, "reading from stdin: "+err.Error())
+		}
+	}
+	if strings.TrimSpace(message) == "" {
+		fail(exitUsage, "Please provide a message to go with the quote")
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	target, relayURL, err := fetchEvent(ptr)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+	showEventPreview(target)
+	fmt.Println()
+
+	fmt.Println(infoStyle.Render("Posting quote..."))
+	results, err := postQuote(sk, target, relayURL, message)
+	if err != nil {
+		fail(exitFailed, "posting: "+err.Error())
+	}
+
+	fmt.Println(successStyle.Render("✓ Quote posted successfully!"))
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+var shortcodeRegex = regexp.MustCompile(`^:([a-zA-Z0-9_-]+):$`)
+
+// postReaction publishes a NIP-25 kind 7 reaction to target. A :shortcode:
+// reaction needs an image URL, taken from emojiURL or, failing that, from
+// the target's own NIP-30 emoji tags.
+func postReaction(sk string, target *nostr.Event, relayURL string, reaction string, emojiURL string) ([]relayResult, error) {
+	if reaction == "" {
+		reaction = "+"
+	}
+
+	tags := nostr.Tags{
+		{"e", target.ID, relayURL, target.PubKey},
+		{"p", target.PubKey, relayURL},
+		{"k", strconv.Itoa(target.Kind)},
+	}
+	if nostr.IsReplaceableKind(target.Kind) || nostr.IsAddressableKind(target.Kind) {
+		addr := nostr.EntityPointer{PublicKey: target.PubKey, Kind: target.Kind, Identifier: target.Tags.GetD()}
+		tags = append(tags, nostr.Tag{"a", addr.AsTagReference(), relayURL, target.PubKey})
+	}
+
+	if m := shortcodeRegex.FindStringSubmatch(reaction); m != nil {
+		shortcode := m[1]
+		if emojiURL == "" {
+			if tag := target.Tags.FindWithValue("emoji", shortcode); len(tag) >= 3 {
+				emojiURL = tag[2]
+			}
+		}
+		if emojiURL == "" {
+			return nil, fmt.Errorf("no image for :%s:, pass one with --emoji-url", shortcode)
+		}
+		tags = append(tags, nostr.Tag{"emoji", shortcode, emojiURL})
+	}
+
+	ev := nostr.Event{
+		Kind:    nostr.KindReaction,
+		Tags:    tags,
+		Content: reaction,
+	}
+
+	return signAndPublish(sk, ev)
+}
+
+func handleReact() {
+	var ref, reaction, emojiURL string
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--emoji-url" && i+1 < len(args):
+			emojiURL = args[i+1]
+			i++
+		case ref == "":
+			ref = args[i]
+		case reaction == "":
+			reaction = args[i]
+		}
+	}
+
+	if ref == "" {
+		fail(exitUsage, "usage: nos react <note1|nevent1|hex-id> [+|-|emoji|:shortcode:] [--emoji-url <url>]")
+	}
+
+	ptr, err := parseEventRef(ref)
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	target, relayURL, err := fetchEvent(ptr)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+	showEventPreview(target)
+	fmt.Println()
+
+	fmt.Println(infoStyle.Render("Sending reaction..."))
+	results, err := postReaction(sk, target, relayURL, reaction, emojiURL)
+	if err != nil {
+		fail(exitFailed, "reacting: "+err.Error())
+	}
+
+	fmt.Println(successStyle.Render("✓ Reaction sent!"))
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+func interactiveReact() {
+	fmt.Println()
+	fmt.Println(titleStyle.Render("React to a Note"))
+
+	var ref string
+	reaction := "+"
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Which note are you reacting to?").
+				Description("note1, nevent1 or hex event ID").
+				Placeholder("note1...").
+				Value(&ref).
+				Validate(func(str string) error {
+					_, err := parseEventRef(str)
+					return err
+				}),
+			huh.NewInput().
+				Title("Reaction").
+				Description("+ to like, - to dislike, any emoji, or a :shortcode: custom emoji").
+				Placeholder("+").
+				Value(&reaction),
+		),
+	)
+
+	err := form.Run()
+	if err != nil {
+		fmt.Println(infoStyle.Render("\nReaction cancelled."))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+
+	ptr, _ := parseEventRef(ref)
+	fmt.Println()
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	target, relayURL, err := fetchEvent(ptr)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError: " + err.Error()))
+		fmt.Print("Press Enter to continue...")
+		fmt.Scanln()
+		return
+	}
+	showEventPreview(target)
+	fmt.Println()
+
+	// A custom emoji needs an image, unless the note already uses it
+	reaction = strings.TrimSpace(reaction)
+	emojiURL := ""
+	if m := shortcodeRegex.FindStringSubmatch(reaction); m != nil && len(target.Tags.FindWithValue("emoji", m[1])) < 3 {
+		err = huh.NewInput().
+			Title("Image for " + reaction).
+			Description("URL of the custom emoji's image").
+			Placeholder("https://...").
+			Value(&emojiURL).
+			Validate(func(str string) error {
+				if !strings.HasPrefix(str, "https://") && !strings.HasPrefix(str, "http://") {
+					return fmt.Errorf("enter an http(s) URL")
+				}
+				return nil
+			}).
+			Run()
+		if err != nil {
+			fmt.Println(infoStyle.Render("\nReaction cancelled."))
+			fmt.Print("Press Enter to continue...")
+			fmt.Scanln()
+			return
+		}
+	}
+
+	_, err = postReaction(sk, target, relayURL, reaction, emojiURL)
+	if err != nil {
+		fmt.Println(errorStyle.Render("\nError reacting: " + err.Error()))
+	} else {
+		fmt.Println(successStyle.Render("\n✓ Reaction sent!"))
+	}
+
+	fmt.Print("\nPress Enter to continue...")
+	fmt.Scanln()
+}
+
+// buildDeletionTags returns the NIP-09 tags for deleting targets: an "e" or
+// "a" tag per target and one "k" tag per kind involved.
+func buildDeletionTags(targets []nostr.Pointer, kinds []int) nostr.Tags {
+	tags := nostr.Tags{}
+	for _, ptr := range targets {
+		switch p := ptr.(type) {
+		case nostr.EventPointer:
+			tags = appendUniqueTags(tags, nostr.Tags{{"e", p.ID}})
+		case nostr.EntityPointer:
+			tags = appendUniqueTags(tags, nostr.Tags{{"a", p.AsTagReference()}})
+		}
+	}
+	for _, kind := range kinds {
+		tags = appendUniqueTags(tags, nostr.Tags{{"k", strconv.Itoa(kind)}})
+	}
+	return tags
+}
+
+// checkDeleted asks every active relay for the deleted targets and reports
+// which relays are still serving them. Addressable targets only count when
+// a version older than the deletion request is still around.
+func checkDeleted(targets []nostr.Pointer, deletedAt nostr.Timestamp) {
+	relays := getActiveRelays()
+
+	filters := []nostr.Filter{}
+	for _, ptr := range targets {
+		filter := ptr.AsFilter()
+		if _, ok := ptr.(nostr.EntityPointer); ok {
+			filter.Until = &deletedAt
+		}
+		filters = append(filters, filter)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	type checkResult struct {
+		url   string
+		found int
+		err   error
+	}
+
+	results := make(chan checkResult, len(relays))
+	for _, url := range relays {
+		go func() {
+			events, err := queryRelay(ctx, url, filters...)
+			found := 0
+			for _, ptr := range targets {
+				for _, ev := range events {
+					if ptr.MatchesEvent(*ev) && ev.CreatedAt <= deletedAt {
+						found++
+						break
+					}
+				}
+			}
+			results <- checkResult{url, found, err}
+		}()
+	}
+
+	stillServing := 0
+	for range relays {
+		res := <-results
+		switch {
+		case res.found > 0:
+			fmt.Printf("  %s %s %s\n", infoStyle.Render("→"), res.url, errorStyle.Render(fmt.Sprintf("still serving %d/%d", res.found, len(targets))))
+			stillServing++
+		case res.err != nil:
+			fmt.Printf("  %s %s %s\n", infoStyle.Render("→"), res.url, errorStyle.Render("could not check: "+res.err.Error()))
+		default:
+			fmt.Printf("  %s %s %s\n", infoStyle.Render("→"), res.url, successStyle.Render("✓ deleted"))
+		}
+	}
+
+	fmt.Println()
+	if stillServing > 0 {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("%d/%d relays are still serving deleted events.", stillServing, len(relays))))
+		fmt.Println(infoStyle.Render("Relays are free to ignore deletion requests, or may take a while to apply them."))
+	} else {
+		fmt.Println(successStyle.Render("No relay is serving the deleted events anymore."))
+	}
+}
+
+func handleDelete() {
+	var refs []string
+	var reason string
+	// The kind of events we can't find, for deleting them without a look
+	kind := -1
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--reason" || args[i] == "-r") && i+1 < len(args):
+			reason = args[i+1]
+			i++
+		case (args[i] == "--kind" || args[i] == "-k") && i+1 < len(args):
+			var err error
+			kind, err = strconv.Atoi(args[i+1])
+			if err != nil || kind < 0 {
+				fail(exitUsage, "--kind must be an event kind number")
+			}
+			i++
+		default:
+			refs = append(refs, args[i])
+		}
+	}
+
+	if len(refs) == 0 {
+		fail(exitUsage, "usage: nos delete <note1|nevent1|naddr1|hex-id>... [--reason <text>] [--kind <n>]")
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+	pub, _ := nostr.GetPublicKey(sk)
+
+	targets := []nostr.Pointer{}
+	kinds := []int{}
+	for _, ref := range refs {
+		ptr, err := parseRef(ref)
+		if err != nil {
+			fail(exitUsage, err.Error())
+		}
+
+		if ap, ok := ptr.(nostr.EntityPointer); ok {
+			if ap.PublicKey != pub {
+				fail(exitUsage, "you can only delete your own events: "+ref)
+			}
+			targets = append(targets, ap)
+			kinds = append(kinds, ap.Kind)
+			continue
+		}
+
+		// Look the event up so we know its kind and that it's ours to delete
+		fmt.Println(infoStyle.Render("Fetching " + truncate(ref, 24) + "..."))
+		ev, _, err := fetchEvent(ptr)
+		if err != nil {
+			if kind == -1 {
+				fail(exitFailed, err.Error()+", so it can't be checked before deleting it (pass --kind <n> to delete it anyway)")
+			}
+			fmt.Println(errorStyle.Render(fmt.Sprintf("  %s, deleting it as kind %d without checking it's yours", err.Error(), kind)))
+			targets = append(targets, ptr)
+			kinds = append(kinds, kind)
+			continue
+		}
+		if ev.PubKey != pub {
+			fail(exitUsage, "you can only delete your own events: "+ref)
+		}
+		targets = append(targets, ptr)
+		kinds = append(kinds, ev.Kind)
+	}
+	fmt.Println()
+
+	deletion := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindDeletion,
+		Tags:      buildDeletionTags(targets, kinds),
+		Content:   reason,
+	}
+
+	fmt.Println(infoStyle.Render("Publishing deletion request..."))
+	results, err := signAndPublish(sk, deletion)
+	if err != nil {
+		fail(exitFailed, "deleting: "+err.Error())
+	}
+	fmt.Println()
+
+	// Give relays a moment to process the request before checking
+	time.Sleep(deletionCheckDelay)
+	fmt.Println(infoStyle.Render("Checking relays for the deleted events..."))
+	checkDeleted(targets, deletion.CreatedAt)
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+// articleFrontmatter is the YAML header of a Markdown article.
+type articleFrontmatter struct {
+	Title       string   `yaml:"title"`
+	Summary     string   `yaml:"summary"`
+	Image       string   `yaml:"image"`
+	PublishedAt string   `yaml:"published_at"`
+	Tags        []string `yaml:"tags"`
+	Slug        string   `yaml:"slug"`
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// parseArticle splits a Markdown file into its YAML frontmatter and body.
+func parseArticle(data string) (articleFrontmatter, string, error) {
+	var meta articleFrontmatter
+
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	if !strings.HasPrefix(data, "---\n") {
+		return meta, strings.TrimSpace(data), nil
+	}
+
+	// The frontmatter ends at the first line that is exactly ---, so
+	// Markdown rules like ---- further down don't cut it short
+	rest := data[len("---\n"):]
+	end := 0
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		if strings.TrimSuffix(line, "\n") == "---" {
+			if err := yaml.Unmarshal([]byte(rest[:end]), &meta); err != nil {
+				return meta, "", fmt.Errorf("invalid frontmatter: %v", err)
+			}
+			return meta, strings.TrimSpace(rest[end+len(line):]), nil
+		}
+		end += len(line)
+	}
+	return meta, "", fmt.Errorf("frontmatter is missing its closing ---")
+}
+
+// parsePublishedAt accepts a unix timestamp, an RFC 3339 time or a plain date.
+func parsePublishedAt(value string) (nostr.Timestamp, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return nostr.Timestamp(n), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return nostr.Timestamp(t.Unix()), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid published_at %q", value)
+}
+
+// slugify turns a title or file name into a d tag identifier.
+func slugify(s string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// buildArticle turns a Markdown file into a NIP-23 long-form event. The d tag
+// comes from the slug so publishing the same file again replaces the article.
+func buildArticle(path string, pub string, draft bool) (nostr.Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	meta, body, err := parseArticle(string(data))
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	if body == "" {
+		return nostr.Event{}, fmt.Errorf("article has no content")
+	}
+
+	slug := meta.Slug
+	if slug == "" {
+		slug = slugify(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	if slug == "" {
+		return nostr.Event{}, fmt.Errorf("could not derive a slug, set one in the frontmatter")
+	}
+
+	kind := nostr.KindArticle
+	if draft {
+		kind = nostr.KindDraftArticle
+	}
+
+	tags := nostr.Tags{{"d", slug}}
+	if meta.Title != "" {
+		tags = append(tags, nostr.Tag{"title", meta.Title})
+	}
+	if meta.Summary != "" {
+		tags = append(tags, nostr.Tag{"summary", meta.Summary})
+	}
+	if meta.Image != "" {
+		tags = append(tags, nostr.Tag{"image", meta.Image})
+	}
+
+	publishedAt := nostr.Now()
+	if meta.PublishedAt != "" {
+		publishedAt, err = parsePublishedAt(meta.PublishedAt)
+		if err != nil {
+			return nostr.Event{}, err
+		}
+	} else if existing, _, err := fetchEvent(nostr.EntityPointer{PublicKey: pub, Kind: kind, Identifier: slug}); err == nil {
+		// Keep the original publication date when updating an article
+		if tag := existing.Tags.Find("published_at"); tag != nil {
+			if ts, err := parsePublishedAt(tag[1]); err == nil {
+				publishedAt = ts
+			}
+		}
+	}
+	tags = append(tags, nostr.Tag{"published_at", strconv.FormatInt(int64(publishedAt), 10)})
+
+	for _, t := range meta.Tags {
+		if t = strings.TrimPrefix(strings.TrimSpace(t), "#"); t != "" {
+			tags = appendUniqueTags(tags, nostr.Tags{{"t", strings.ToLower(t)}})
+		}
+	}
+
+	// Links and mentions still get indexed, but Markdown anchors aren't hashtags
+	tags = appendUniqueTags(tags, extractContentTags(body).FilterOut([]string{"t"}))
+
+	return nostr.Event{
+		Kind:    kind,
+		Tags:    tags,
+		Content: body,
+	}, nil
+}
+
+func handleArticleCommand() {
+	if len(os.Args) < 3 || os.Args[2] != "publish" {
+		showArticleUsage()
+		os.Exit(exitUsage)
+	}
+
+	var path string
+	draft := false
+	for _, arg := range os.Args[3:] {
+		if arg == "--draft" {
+			draft = true
+		} else if path == "" {
+			path = arg
+		}
+	}
+	if path == "" {
+		showArticleUsage()
+		os.Exit(exitUsage)
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+	pub, _ := nostr.GetPublicKey(sk)
+
+	ev, err := buildArticle(path, pub, draft)
+	if err != nil {
+		fail(exitFailed, "reading article: "+err.Error())
+	}
+
+	if draft {
+		fmt.Println(infoStyle.Render("Publishing draft article..."))
+	} else {
+		fmt.Println(infoStyle.Render("Publishing article..."))
+	}
+	if title := ev.Tags.Find("title"); title != nil {
+		fmt.Println(infoStyle.Render("Title: " + title[1]))
+	}
+
+	results, err := signAndPublish(sk, ev)
+	if err != nil {
+		fail(exitFailed, "publishing: "+err.Error())
+	}
+
+	relays := getActiveRelays()
+	naddr, _ := nip19.EncodeEntity(pub, ev.Kind, ev.Tags.GetD(), relays[:min(len(relays), 1)])
+	fmt.Println(successStyle.Render("✓ Article published!"))
+	fmt.Println(infoStyle.Render("Address: " + naddr))
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+func showArticleUsage() {
+	fmt.Println(titleStyle.Render("Long-form Articles"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos article publish <file.md>          - Publish or update an article"))
+	fmt.Println(infoStyle.Render("  nos article publish <file.md> --draft  - Publish as a draft"))
+	fmt.Println(infoStyle.Render("\nFrontmatter keys: title, summary, image, published_at, tags, slug"))
+}
+
+// Local data files
+//
+// Secrets and the relay list live in the keyring, but queues and caches can
+// grow beyond what keyrings are happy to store, so they go in JSON files under
+// the user's config directory.
+
+// dataPath returns the path of a data file, creating the nos config
+// directory if needed.
+func dataPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, appName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// loadData reads a JSON data file into v. A missing file leaves v untouched.
+func loadData(name string, v any) error {
+	path, err := dataPath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveData atomically writes v to a JSON data file.
+func saveData(name string, v any) error {
+	path, err := dataPath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// scheduledPost is a signed event waiting in the local queue until its
+// created_at time comes around.
+type scheduledPost struct {
+	Event    nostr.Event `json:"event"`
+	QueuedAt int64       `json:"queued_at"`
+}
+
+var relativeDaysRegex = regexp.MustCompile(`^(\d+)d$`)
+
+// parseScheduleTime understands durations ("2h", "in 30m", "+1d"), clock
+// times ("09:30", today or tomorrow) and dates ("2025-01-02 09:30").
+func parseScheduleTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	relative := strings.TrimPrefix(strings.TrimPrefix(value, "in "), "+")
+	if m := relativeDaysRegex.FindStringSubmatch(relative); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		return now.AddDate(0, 0, days), nil
+	}
+	if d, err := time.ParseDuration(relative); err == nil {
+		return now.Add(d), nil
+	}
+
+	if t, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q (try \"2h\", \"09:30\" or \"2025-01-02 09:30\")", value)
+}
+
+func loadSchedule() ([]scheduledPost, error) {
+	var queue []scheduledPost
+	err := loadData(scheduleFile, &queue)
+	return queue, err
+}
+
+func storeSchedule(queue []scheduledPost) error {
+	return saveData(scheduleFile, queue)
+}
+
+func handleScheduleCommand() {
+	if len(os.Args) < 3 {
+		showScheduleUsage()
+		os.Exit(exitUsage)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		listSchedule()
+		return
+	case "cancel":
+		if len(os.Args) < 4 {
+			showScheduleUsage()
+			os.Exit(exitUsage)
+		}
+		cancelScheduled(os.Args[3])
+		return
+	case "run":
+		once := len(os.Args) >= 4 && os.Args[3] == "--once"
+		runSchedule(once)
+		return
+	}
+
+	if len(os.Args) < 4 {
+		showScheduleUsage()
+		os.Exit(exitUsage)
+	}
+	schedulePost(os.Args[2], strings.Join(os.Args[3:], " "))
+}
+
+func schedulePost(when string, message string) {
+	at, err := parseScheduleTime(when, time.Now())
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+	if !at.After(time.Now()) {
+		fail(exitUsage, "scheduled time is in the past")
+	}
+	if strings.TrimSpace(message) == "" {
+		fail(exitUsage, "Please provide a message to schedule")
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+
+	// Sign now with the scheduled timestamp, so the queue never needs the key
+	ev := nostr.Event{
+		CreatedAt: nostr.Timestamp(at.Unix()),
+		Kind:      nostr.KindTextNote,
+		Tags:      extractContentTags(message),
+		Content:   message,
+	}
+	err = signEvent(sk, &ev)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+
+	queue, err := loadSchedule()
+	if err != nil {
+		fail(exitFailed, "reading schedule: "+err.Error())
+	}
+	queue = append(queue, scheduledPost{Event: ev, QueuedAt: time.Now().Unix()})
+	err = storeSchedule(queue)
+	if err != nil {
+		fail(exitFailed, "storing schedule: "+err.Error())
+	}
+
+	showEventDetails(ev)
+	fmt.Println(successStyle.Render("✓ Scheduled for " + at.Format("2006-01-02 15:04")))
+	fmt.Println(infoStyle.Render("Run 'nos schedule run' (or add 'nos schedule run --once' to cron) to publish it."))
+}
+
+func listSchedule() {
+	queue, err := loadSchedule()
+	if err != nil {
+		fail(exitFailed, "reading schedule: "+err.Error())
+	}
+
+	fmt.Println(titleStyle.Render("Scheduled Posts"))
+	if len(queue) == 0 {
+		fmt.Println(infoStyle.Render("Nothing scheduled."))
+		return
+	}
+
+	for i, post := range queue {
+		at := post.Event.CreatedAt.Time().Format("2006-01-02 15:04")
+		status := ""
+		if post.Event.CreatedAt <= nostr.Now() {
+			status = errorStyle.Render(" (due)")
+		}
+		fmt.Printf("%s %d. [%s]%s %s %s\n", infoStyle.Render("•"), i+1, at, status, post.Event.ID[:8], truncate(post.Event.Content, 50))
+	}
+}
+
+// cancelScheduled removes a queued post by list number or event ID prefix.
+func cancelScheduled(ref string) {
+	queue, err := loadSchedule()
+	if err != nil {
+		fail(exitFailed, "reading schedule: "+err.Error())
+	}
+
+	index := -1
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(queue) {
+		index = n - 1
+	} else {
+		for i, post := range queue {
+			if strings.HasPrefix(post.Event.ID, ref) {
+				index = i
+				break
+			}
+		}
+	}
+	if index == -1 {
+		fail(exitUsage, "no scheduled post matches: "+ref)
+	}
+
+	cancelled := queue[index]
+	queue = append(queue[:index], queue[index+1:]...)
+	err = storeSchedule(queue)
+	if err != nil {
+		fail(exitFailed, "storing schedule: "+err.Error())
+	}
+
+	fmt.Println(successStyle.Render("✓ Cancelled: " + truncate(cancelled.Event.Content, 50)))
+}
+
+// runSchedule publishes every due post. Unless once is set it keeps
+// polling the queue until interrupted.
+func runSchedule(once bool) {
+	if !once {
+		fmt.Println(titleStyle.Render("Schedule Runner"))
+		fmt.Println(infoStyle.Render("Publishing scheduled posts as they come due. Press Ctrl+C to stop."))
+	}
+
+	for {
+		retryDueOutbox()
+		published := publishDue()
+		if once {
+			if published == 0 {
+				fmt.Println(infoStyle.Render("No scheduled posts are due."))
+			}
+			return
+		}
+		time.Sleep(schedulePollInterval)
+	}
+}
+
+// publishDue publishes due posts and drops them from the queue once a relay
+// has accepted them or the outbox has taken them over. Posts that went
+// nowhere stay queued for the next run. It returns how many were published.
+func publishDue() int {
+	queue, err := loadSchedule()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error reading schedule: " + err.Error()))
+		return 0
+	}
+
+	done := make(map[string]bool)
+	for _, post := range queue {
+		if post.Event.CreatedAt > nostr.Now() {
+			continue
+		}
+
+		fmt.Println()
+		fmt.Println(infoStyle.Render("Publishing scheduled post " + post.Event.ID[:8] + ": " + truncate(post.Event.Content, 50)))
+		// Relays that fail are queued in the outbox, which takes care of retries
+		if err := publishEvent(post.Event); err != nil {
+			fmt.Println(errorStyle.Render("Error posting: " + err.Error()))
+			if !inOutbox(post.Event.ID) {
+				fmt.Println(infoStyle.Render("Keeping it scheduled to try again."))
+				continue
+			}
+		}
+		done[post.Event.ID] = true
+	}
+
+	if len(done) == 0 {
+		return 0
+	}
+
+	// Reload so posts scheduled while we were publishing aren't lost
+	queue, err = loadSchedule()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error reading schedule: " + err.Error()))
+		return len(done)
+	}
+	remaining := []scheduledPost{}
+	for _, post := range queue {
+		if !done[post.Event.ID] {
+			remaining = append(remaining, post)
+		}
+	}
+	if err := storeSchedule(remaining); err != nil {
+		fmt.Println(errorStyle.Render("Error storing schedule: " + err.Error()))
+	}
+
+	return len(done)
+}
+
+func showScheduleUsage() {
+	fmt.Println(titleStyle.Render("Scheduled Posts"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos schedule \"<time>\" <message> - Schedule a post"))
+	fmt.Println(infoStyle.Render("  nos schedule list               - List scheduled posts"))
+	fmt.Println(infoStyle.Render("  nos schedule cancel <n|id>      - Cancel a scheduled post"))
+	fmt.Println(infoStyle.Render("  nos schedule run                - Publish posts as they come due"))
+	fmt.Println(infoStyle.Render("  nos schedule run --once         - Publish due posts and exit (for cron)"))
+	fmt.Println(infoStyle.Render("\nTimes can be \"2h\", \"in 30m\", \"09:30\" or \"2025-01-02 09:30\"."))
+}
+
+// outboxEntry is a signed event that some relays haven't accepted yet.
+type outboxEntry struct {
+	Event       nostr.Event       `json:"event"`
+	Relays      []string          `json:"relays"`
+	Errors      map[string]string `json:"errors,omitempty"`
+	Pinned      []string          `json:"pinned,omitempty"` // retried even when not in the relay list
+	Attempts    int               `json:"attempts"`
+	NextAttempt int64             `json:"next_attempt"`
+}
+
+func loadOutbox() ([]outboxEntry, error) {
+	var outbox []outboxEntry
+	err := loadData(outboxFile, &outbox)
+	return outbox, err
+}
+
+func storeOutbox(outbox []outboxEntry) error {
+	return saveData(outboxFile, outbox)
+}
+
+// outboxBackoff doubles the retry delay with every attempt, up to a cap.
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 0; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
+
+// queueOutbox records the relays that rejected ev so they can be retried.
+// Pinned relays are retried even if they aren't in the relay list.
+func queueOutbox(ev nostr.Event, failed []relayResult, pinned []string) error {
+	outbox, err := loadOutbox()
+	if err != nil {
+		return err
+	}
+
+	index := -1
+	for i, entry := range outbox {
+		if entry.Event.ID == ev.ID {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		outbox = append(outbox, outboxEntry{Event: ev, Errors: map[string]string{}})
+		index = len(outbox) - 1
+	}
+
+	entry := &outbox[index]
+	if entry.Errors == nil {
+		entry.Errors = map[string]string{}
+	}
+	for _, res := range failed {
+		entry.Relays = mergeRelays(entry.Relays, []string{res.url})
+		entry.Errors[res.url] = res.describe()
+	}
+	entry.Pinned = mergeRelays(entry.Pinned, pinned)
+	entry.NextAttempt = time.Now().Add(outboxBackoff(entry.Attempts)).Unix()
+
+	return storeOutbox(outbox)
+}
+
+// inOutbox reports whether the event with the given ID is waiting in the
+// outbox.
+func inOutbox(id string) bool {
+	outbox, err := loadOutbox()
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(outbox, func(entry outboxEntry) bool {
+		return entry.Event.ID == id
+	})
+}
+
+// outboxDelivered drops relays that have since accepted the event from its
+// outbox entry, and the entry itself once nothing is left.
+func outboxDelivered(id string, urls []string) error {
+	outbox, err := loadOutbox()
+	if err != nil {
+		return err
+	}
+
+	delivered := make(map[string]bool)
+	for _, url := range urls {
+		delivered[nostr.NormalizeURL(url)] = true
+	}
+	kept := []outboxEntry{}
+	for _, entry := range outbox {
+		if entry.Event.ID == id {
+			relays := []string{}
+			for _, url := range entry.Relays {
+				if delivered[nostr.NormalizeURL(url)] {
+					delete(entry.Errors, url)
+				} else {
+					relays = append(relays, url)
+				}
+			}
+			if len(relays) == 0 {
+				continue
+			}
+			entry.Relays = relays
+		}
+		kept = append(kept, entry)
+	}
+	return storeOutbox(kept)
+}
+
+// inactiveRelays returns the relays that aren't in the active relay list.
+func inactiveRelays(relays []string) []string {
+	active := make(map[string]bool)
+	for _, url := range getActiveRelays() {
+		active[nostr.NormalizeURL(url)] = true
+	}
+	inactive := []string{}
+	for _, url := range relays {
+		if !active[nostr.NormalizeURL(url)] {
+			inactive = append(inactive, url)
+		}
+	}
+	return inactive
+}
+
+// retryOutbox tries pending relays again for every entry that is due, or for
+// all of them when force is set. Relays that accept an event, or that have
+// since been removed from the relay list and weren't pinned, are dropped
+// from its entry. Entries left with no relays count as delivered if one of
+// them accepted the event, and as dropped if they were all given up on.
+func retryOutbox(force bool) (delivered, dropped, remaining int) {
+	outbox, err := loadOutbox()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error reading outbox: " + err.Error()))
+		return 0, 0, 0
+	}
+
+	active := make(map[string]bool)
+	for _, url := range getActiveRelays() {
+		active[nostr.NormalizeURL(url)] = true
+	}
+
+	updated := make(map[string]*outboxEntry)
+	now := time.Now()
+	for _, entry := range outbox {
+		if !force && entry.NextAttempt > now.Unix() {
+			continue
+		}
+
+		pinned := make(map[string]bool)
+		for _, url := range entry.Pinned {
+			pinned[nostr.NormalizeURL(url)] = true
+		}
+		relays := []string{}
+		for _, url := range entry.Relays {
+			if active[nostr.NormalizeURL(url)] || pinned[nostr.NormalizeURL(url)] {
+				relays = append(relays, url)
+			}
+		}
+
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Retrying %s (%s) on %d relays...", entry.Event.ID[:8], truncate(entry.Event.Content, 40), len(relays))))
+
+		retried := entry
+		retried.Relays = []string{}
+		retried.Errors = map[string]string{}
+		accepted := false
+		for _, res := range broadcastEvent(entry.Event, relays) {
+			// Relays that refused the event outright are given up on
+			if res.err == nil {
+				accepted = true
+			} else if shouldRetry(res.err) {
+				retried.Relays = append(retried.Relays, res.url)
+				retried.Errors[res.url] = res.describe()
+			} else {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("  %s refused %s: %s", res.url, entry.Event.ID[:8], res.describe())))
+			}
+		}
+		retried.Attempts++
+		retried.NextAttempt = now.Add(outboxBackoff(retried.Attempts)).Unix()
+
+		if len(retried.Relays) == 0 {
+			if accepted {
+				delivered++
+			} else {
+				dropped++
+			}
+			updated[entry.Event.ID] = nil
+		} else {
+			updated[entry.Event.ID] = &retried
+		}
+	}
+
+	if len(updated) == 0 {
+		return 0, 0, len(outbox)
+	}
+
+	// Reload so events queued while we were retrying aren't lost
+	outbox, err = loadOutbox()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error reading outbox: " + err.Error()))
+		return delivered, dropped, 0
+	}
+	kept := []outboxEntry{}
+	for _, entry := range outbox {
+		if retried, ok := updated[entry.Event.ID]; ok {
+			if retried != nil {
+				kept = append(kept, *retried)
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	if err := storeOutbox(kept); err != nil {
+		fmt.Println(errorStyle.Render("Error storing outbox: " + err.Error()))
+	}
+
+	return delivered, dropped, len(kept)
+}
+
+// retryDueOutbox quietly checks the outbox at startup and retries anything
+// whose backoff has expired.
+func retryDueOutbox() {
+	outbox, err := loadOutbox()
+	if err != nil || len(outbox) == 0 {
+		return
+	}
+
+	due := 0
+	for _, entry := range outbox {
+		if entry.NextAttempt <= time.Now().Unix() {
+			due++
+		}
+	}
+	if due == 0 {
+		return
+	}
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Retrying %d undelivered events from the outbox...", due)))
+	delivered, dropped, remaining := retryOutbox(false)
+	if delivered > 0 {
+		fmt.Println(successStyle.Render(fmt.Sprintf("✓ Delivered %d events from the outbox", delivered)))
+	}
+	if dropped > 0 {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Gave up on %d events no relay would accept", dropped)))
+	}
+	if remaining > 0 {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("%d events are still waiting in the outbox", remaining)))
+	}
+	fmt.Println()
+}
+
+func handleOutboxCommand() {
+	if len(os.Args) < 3 {
+		showOutboxUsage()
+		return
+	}
+
+	switch os.Args[2] {
+	case "status":
+		showOutboxStatus()
+	case "flush":
+		outbox, err := loadOutbox()
+		if err != nil {
+			fail(exitFailed, "reading outbox: "+err.Error())
+		}
+		if len(outbox) == 0 {
+			fmt.Println(successStyle.Render("✓ Outbox is empty, everything has been delivered."))
+			return
+		}
+
+		delivered, dropped, remaining := retryOutbox(true)
+		fmt.Println()
+		if dropped > 0 {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("Gave up on %d events no relay would accept", dropped)))
+		}
+		if remaining == 0 && dropped == 0 {
+			fmt.Println(successStyle.Render(fmt.Sprintf("✓ Delivered %d events, outbox is empty", delivered)))
+			return
+		}
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Delivered %d events, %d still waiting", delivered, remaining)))
+		if delivered > 0 {
+			os.Exit(exitPartial)
+		}
+		os.Exit(exitFailed)
+	default:
+		showOutboxUsage()
+	}
+}
+
+func showOutboxStatus() {
+	outbox, err := loadOutbox()
+	if err != nil {
+		fail(exitFailed, "reading outbox: "+err.Error())
+	}
+
+	fmt.Println(titleStyle.Render("Outbox"))
+	if len(outbox) == 0 {
+		fmt.Println(successStyle.Render("✓ Everything has been delivered."))
+		return
+	}
+
+	for _, entry := range outbox {
+		next := time.Unix(entry.NextAttempt, 0).Format("2006-01-02 15:04")
+		fmt.Printf("%s %s %s\n", infoStyle.Render("•"), entry.Event.ID[:8], truncate(entry.Event.Content, 50))
+		fmt.Printf("    %s\n", infoStyle.Render(fmt.Sprintf("%d attempts, next retry %s", entry.Attempts, next)))
+		for _, url := range entry.Relays {
+			fmt.Printf("    %s %s %s\n", errorStyle.Render("✗"), url, errorStyle.Render(entry.Errors[url]))
+		}
+	}
+}
+
+func showOutboxUsage() {
+	fmt.Println(titleStyle.Render("Outbox"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos outbox status          - Show events some relays haven't accepted yet"))
+	fmt.Println(infoStyle.Render("  nos outbox flush           - Retry every pending relay now"))
+}
+
+// editorScissors marks the start of the instructions in the editor template.
+// Everything from this line on is dropped, so hashtags at the start of a
+// line survive, unlike with '#' comment lines.
+const editorScissors = "# ------------------------ >8 ------------------------"
+
+const editorInstructions = editorScissors + `
+# Write your post above this line. Everything from the line above down
+# is ignored. Save and close the editor to publish, or leave the post
+# empty to cancel.
+#
+# Hashtags, links and nostr: mentions are tagged automatically.
+`
+
+// editorCommand returns the user's preferred editor.
+func editorCommand() string {
+	if editor := os.Getenv("VISUAL"); editor != "" {
+		return editor
+	}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editorProcess runs editor on path through the shell, so editors set up
+// with arguments or quoted paths work as they do for git.
+func editorProcess(editor, path string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		// cmd.exe expands the variable before parsing the line, which
+		// keeps Go's argument quoting out of the way
+		cmd := exec.Command("cmd", "/C", "%NOS_EDITOR_COMMAND%")
+		cmd.Env = append(os.Environ(), "NOS_EDITOR_COMMAND="+editor+` "`+path+`"`)
+		return cmd
+	}
+	return exec.Command("sh", "-c", editor+` "$1"`, editor, path)
+}
+
+// composeInEditor opens $VISUAL or $EDITOR on a temporary file seeded with
+// initial and returns what the user wrote above the scissors line.
+func composeInEditor(initial string) (string, error) {
+	f, err := os.CreateTemp("", "nos-post-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	template := initial
+	if template != "" && !strings.HasSuffix(template, "\n") {
+		template += "\n"
+	}
+	template += "\n" + editorInstructions
+	_, err = f.WriteString(template)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	editor := editorCommand()
+	cmd := editorProcess(editor, f.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %v", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if i := strings.Index(text, editorScissors); i != -1 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// draft is a post that hasn't been published yet, along with the content
+// warning and expiration it should be posted with.
+type draft struct {
+	ID             string     `json:"id"`
+	Content        string     `json:"content"`
+	ContentWarning *string    `json:"content_warning,omitempty"`
+	ExpiresAt      int64      `json:"expires_at,omitempty"`
+	Media          nostr.Tags `json:"media,omitempty"`
+	UpdatedAt      int64      `json:"updated_at"`
+}
+
+// options returns the post options saved with the draft.
+func (d draft) options() postOptions {
+	opts := postOptions{expiresAt: nostr.Timestamp(d.ExpiresAt), media: d.Media}
+	if d.ContentWarning != nil {
+		opts.contentWarning = true
+		opts.contentWarningMsg = *d.ContentWarning
+	}
+	return opts
+}
+
+func loadDrafts() ([]draft, error) {
+	var drafts []draft
+	err := loadData(draftsFile, &drafts)
+	return drafts, err
+}
+
+func storeDrafts(drafts []draft) error {
+	return saveData(draftsFile, drafts)
+}
+
+// saveDraft stores content under id, or as a new draft when id is empty,
+// and returns the draft's ID.
+func saveDraft(id string, content string, opts postOptions) (string, error) {
+	drafts, err := loadDrafts()
+	if err != nil {
+		return id, err
+	}
+
+	d := draft{ID: id, Content: content, ExpiresAt: int64(opts.expiresAt), Media: opts.media, UpdatedAt: time.Now().Unix()}
+	if opts.contentWarning {
+		d.ContentWarning = &opts.contentWarningMsg
+	}
+
+	if index := findDraft(drafts, id); id != "" && index != -1 {
+		drafts[index] = d
+	} else {
+		d.ID = strconv.FormatInt(time.Now().UnixNano(), 36)
+		drafts = append(drafts, d)
+	}
+
+	return d.ID, storeDrafts(drafts)
+}
+
+func deleteDraft(id string) error {
+	drafts, err := loadDrafts()
+	if err != nil {
+		return err
+	}
+
+	kept := []draft{}
+	for _, d := range drafts {
+		if d.ID != id {
+			kept = append(kept, d)
+		}
+	}
+	return storeDrafts(kept)
+}
+
+// findDraft looks a draft up by list number or ID and returns its index,
+// or -1 if there is no match.
+func findDraft(drafts []draft, ref string) int {
+	if ref == "" {
+		return -1
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(drafts) {
+		return n - 1
+	}
+	for i, d := range drafts {
+		if d.ID == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveFailedDraft keeps a message that couldn't be posted.
+func saveFailedDraft(id string, message string, opts postOptions) {
+	if _, err := saveDraft(id, message, opts); err != nil {
+		fmt.Println(errorStyle.Render("Error saving draft: " + err.Error()))
+		return
+	}
+	fmt.Println(infoStyle.Render("Your post was kept as a draft, see 'nos drafts list'."))
+}
+
+// publishDraft posts message, keeping it as a draft until the signed event
+// has been handed to the relays (or to the outbox if none accepted it).
+func publishDraft(sk string, id string, message string, opts postOptions) (nostr.Event, []relayResult, error) {
+	id, saveErr := saveDraft(id, message, opts)
+
+	ev, results, err := postToNostr(sk, message, opts)
+	if err != nil && !errors.Is(err, errNoRelayAccepted) {
+		if saveErr == nil {
+			fmt.Println(infoStyle.Render("Your post was kept as a draft, see 'nos drafts list'."))
+		}
+		return ev, results, err
+	}
+
+	if saveErr == nil {
+		deleteDraft(id)
+	}
+	return ev, results, err
+}
+
+func handleDraftsCommand() {
+	command := "list"
+	if len(os.Args) >= 3 {
+		command = os.Args[2]
+	}
+	ref := ""
+	if len(os.Args) >= 4 {
+		ref = os.Args[3]
+	}
+
+	drafts, err := loadDrafts()
+	if err != nil {
+		fail(exitFailed, "reading drafts: "+err.Error())
+	}
+
+	if command == "list" {
+		fmt.Println(titleStyle.Render("Drafts"))
+		if len(drafts) == 0 {
+			fmt.Println(infoStyle.Render("No drafts."))
+			return
+		}
+		for i, d := range drafts {
+			updated := time.Unix(d.UpdatedAt, 0).Format("2006-01-02 15:04")
+			fmt.Printf("%s %d. [%s] %s\n", infoStyle.Render("•"), i+1, updated, truncate(d.Content, 60))
+		}
+		return
+	}
+
+	index := findDraft(drafts, ref)
+	if index == -1 {
+		if ref == "" {
+			showDraftsUsage()
+		} else {
+			fmt.Println(errorStyle.Render("No draft matches: " + ref))
+		}
+		os.Exit(exitUsage)
+	}
+	d := drafts[index]
+
+	switch command {
+	case "edit":
+		content, err := composeInEditor(d.Content)
+		if err != nil {
+			fail(exitFailed, err.Error())
+		}
+		if content == "" {
+			fmt.Println(infoStyle.Render("Empty message, draft left unchanged."))
+			return
+		}
+		if _, err := saveDraft(d.ID, content, d.options()); err != nil {
+			fail(exitFailed, "saving draft: "+err.Error())
+		}
+		fmt.Println(successStyle.Render("✓ Draft saved"))
+	case "post":
+		sk, err := loadSecretKey()
+		if err != nil {
+			fail(exitNoSetup, err.Error())
+		}
+		fmt.Println(infoStyle.Render("Posting to Nostr..."))
+		opts := d.options()
+		if opts.expiresAt != 0 && opts.expiresAt <= nostr.Now() {
+			fail(exitFailed, "this draft's expiration time has already passed")
+		}
+		_, results, err := publishDraft(sk, d.ID, d.Content, opts)
+		if err != nil {
+			fail(exitFailed, "posting: "+err.Error())
+		}
+		fmt.Println(successStyle.Render("✓ Posted successfully!"))
+		os.Exit(publishExitCode(results, nil, opts))
+	case "rm", "remove", "delete":
+		if err := deleteDraft(d.ID); err != nil {
+			fail(exitFailed, "removing draft: "+err.Error())
+		}
+		fmt.Println(successStyle.Render("✓ Removed draft: " + truncate(d.Content, 50)))
+	default:
+		showDraftsUsage()
+		os.Exit(exitUsage)
+	}
+}
+
+func showDraftsUsage() {
+	fmt.Println(titleStyle.Render("Drafts"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos drafts list            - List saved drafts"))
+	fmt.Println(infoStyle.Render("  nos drafts edit <n>        - Edit a draft in $EDITOR"))
+	fmt.Println(infoStyle.Render("  nos drafts post <n>        - Publish a draft"))
+	fmt.Println(infoStyle.Render("  nos drafts rm <n>          - Delete a draft"))
+}
+
+var (
+	threadSeparatorRegex = regexp.MustCompile(`(?m)^[ \t]*---[ \t]*$`)
+	threadTokenRegex     = regexp.MustCompile(`\S+\s*`)
+)
+
+// splitThread breaks text into notes at explicit "---" lines, then splits
+// anything longer than maxChars at word boundaries. URLs are never broken up,
+// even if that leaves a note over budget. With numbered set, room is left
+// for a "1/n" counter at the end of each note.
+func splitThread(text string, maxChars int, numbered bool) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var sections []string
+	for _, section := range threadSeparatorRegex.Split(text, -1) {
+		if section = strings.TrimSpace(section); section != "" {
+			sections = append(sections, section)
+		}
+	}
+
+	split := func(budget int) []string {
+		var notes []string
+		for _, section := range sections {
+			notes = append(notes, splitToBudget(section, budget)...)
+		}
+		return notes
+	}
+
+	notes := split(maxChars)
+	if !numbered {
+		return notes
+	}
+
+	// The counter's width depends on how many notes there are, so settle it
+	for i := 0; i < 3; i++ {
+		reserve := len(threadCounter(len(notes), len(notes)))
+		again := split(maxChars - reserve)
+		if len(again) == len(notes) {
+			notes = again
+			break
+		}
+		notes = again
+	}
+
+	for i := range notes {
+		notes[i] += threadCounter(i+1, len(notes))
+	}
+	return notes
+}
+
+func threadCounter(n int, total int) string {
+	return fmt.Sprintf("\n\n%d/%d", n, total)
+}
+
+// splitToBudget packs whitespace-separated words into chunks of at most
+// budget characters, keeping line breaks within a chunk.
+func splitToBudget(text string, budget int) []string {
+	if budget < 1 {
+		budget = 1
+	}
+	if len([]rune(text)) <= budget {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, token := range threadTokenRegex.FindAllString(text, -1) {
+		word := strings.TrimRightFunc(token, unicode.IsSpace)
+		if len([]rune(current.String()))+len([]rune(word)) > budget {
+			flush()
+		}
+
+		// Words that can't fit anywhere get cut, but links stay whole
+		for len([]rune(word)) > budget && !urlRegex.MatchString(word) {
+			runes := []rune(word)
+			chunks = append(chunks, string(runes[:budget]))
+			word = string(runes[budget:])
+			token = word + token[len(strings.TrimRightFunc(token, unicode.IsSpace)):]
+		}
+
+		current.WriteString(token)
+	}
+	flush()
+
+	return chunks
+}
+
+func handleThread() {
+	var path string
+	maxChars := defaultThreadChars
+	numbered := false
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--max" || args[i] == "-m") && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 20 {
+				fail(exitUsage, "--max must be a number of at least 20")
+			}
+			maxChars = n
+			i++
+		case args[i] == "--number" || args[i] == "-n":
+			numbered = true
+		case path == "":
+			path = args[i]
+		}
+	}
+
+	var text string
+	var err error
+	switch {
+	case path != "" && path != "-":
+		var data []byte
+		data, err = os.ReadFile(path)
+		text = string(data)
+	case hasStdin():
+		text, err = readStdin()
+	default:
+		showThreadUsage()
+		os.Exit(exitUsage)
+	}
+	if err != nil {
+		fail(exitFailed, "reading thread: "+err.Error())
+	}
+
+	notes := splitThread(text, maxChars, numbered)
+	if len(notes) == 0 {
+		fail(exitUsage, "nothing to post")
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+	pub, _ := nostr.GetPublicKey(sk)
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Posting a thread of %d notes", len(notes))))
+
+	relayHint := getActiveRelays()[0]
+	start := nostr.Now()
+	var previous *nostr.Event
+	var codes []int
+	for i, note := range notes {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Note %d/%d", i+1, len(notes))))
+
+		tags := extractContentTags(note)
+		if previous != nil {
+			tags = appendUniqueTags(buildReplyTags(previous, relayHint, pub), tags)
+		}
+
+		// Space the timestamps out so clients keep the notes in order
+		ev := nostr.Event{
+			CreatedAt: start + nostr.Timestamp(i),
+			Kind:      nostr.KindTextNote,
+			Tags:      tags,
+			Content:   note,
+		}
+		err = signEvent(sk, &ev)
+		if err != nil {
+			fail(exitFailed, err.Error())
+		}
+
+		showEventDetails(ev)
+		results, err := publishEventResults(ev, getActiveRelays())
+		if err != nil && !errors.Is(err, errNoRelayAccepted) {
+			fail(exitFailed, "posting: "+err.Error())
+		}
+		codes = append(codes, publishExitCode(results, err, postOptions{}))
+		fmt.Println()
+
+		previous = &ev
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Thread of %d notes posted!", len(notes))))
+	os.Exit(combineExitCodes(codes))
+}
+
+func showThreadUsage() {
+	fmt.Println(titleStyle.Render("Threads"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos thread <file>          - Post a file as a thread"))
+	fmt.Println(infoStyle.Render("  cat post.txt | nos thread  - Post stdin as a thread"))
+	fmt.Println(infoStyle.Render("\nOptions:"))
+	fmt.Println(infoStyle.Render(fmt.Sprintf("  --max <n>                  - Characters per note (default %d)", defaultThreadChars)))
+	fmt.Println(infoStyle.Render("  --number                   - Add 1/n counters to each note"))
+	fmt.Println(infoStyle.Render("\nLines containing only --- start a new note."))
+}
+
+// cachedRelayInfo is a relay's NIP-11 document and when we fetched it.
+type cachedRelayInfo struct {
+	Info      nip11.RelayInformationDocument `json:"info"`
+	Failed    bool                           `json:"failed,omitempty"`
+	FetchedAt int64                          `json:"fetched_at"`
+}
+
+// getRelayInfo returns the NIP-11 documents of the given relays, fetching
+// the ones that aren't cached (or whose cache is stale) in parallel. Relays
+// that don't serve a document are left out, and remembered for a while so
+// they don't hold up every post.
+func getRelayInfo(relays []string) map[string]nip11.RelayInformationDocument {
+	cache := map[string]cachedRelayInfo{}
+	loadData(relayInfoFile, &cache)
+
+	infos := make(map[string]nip11.RelayInformationDocument)
+	stale := []string{}
+	for _, url := range relays {
+		cached, ok := cache[nostr.NormalizeURL(url)]
+		ttl := relayInfoTTL
+		if cached.Failed {
+			ttl = relayInfoFailureTTL
+		}
+		if ok && time.Since(time.Unix(cached.FetchedAt, 0)) < ttl {
+			if !cached.Failed {
+				infos[url] = cached.Info
+			}
+			continue
+		}
+		stale = append(stale, url)
+	}
+	if len(stale) == 0 {
+		return infos
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayInfoTimeout)
+	defer cancel()
+
+	type infoResult struct {
+		url  string
+		info nip11.RelayInformationDocument
+		err  error
+	}
+
+	results := make(chan infoResult, len(stale))
+	for _, url := range stale {
+		go func() {
+			info, err := nip11.Fetch(ctx, url)
+			results <- infoResult{url, info, err}
+		}()
+	}
+
+	for range stale {
+		res := <-results
+		if res.err != nil {
+			cache[nostr.NormalizeURL(res.url)] = cachedRelayInfo{Failed: true, FetchedAt: time.Now().Unix()}
+			continue
+		}
+		infos[res.url] = res.info
+		cache[nostr.NormalizeURL(res.url)] = cachedRelayInfo{Info: res.info, FetchedAt: time.Now().Unix()}
+	}
+
+	saveData(relayInfoFile, cache)
+	return infos
+}
+
+// relaysMinPow returns the highest NIP-13 difficulty any of the relays
+// advertises as its minimum.
+func relaysMinPow(relays []string) int {
+	required := 0
+	for _, info := range getRelayInfo(relays) {
+		if info.Limitation != nil && info.Limitation.MinPowDifficulty > required {
+			required = info.Limitation.MinPowDifficulty
+		}
+	}
+	return required
+}
+
+var powDifficultyRegex = regexp.MustCompile(`\d+`)
+
+// powRequirement parses a "pow:" rejection and returns the difficulty the
+// relay asked for, or 0 if the error isn't a PoW rejection. Relays word
+// these differently ("pow: difficulty 12 is less than 20"), so the largest
+// number in the message is taken as the requirement.
+func powRequirement(err error) int {
+	var re *relayError
+	if !errors.As(err, &re) || re.prefix != reasonPow {
+		return 0
+	}
+
+	required := 0
+	for _, n := range powDifficultyRegex.FindAllString(re.message, -1) {
+		if d, err := strconv.Atoi(n); err == nil && d > required && d <= 256 {
+			required = d
+		}
+	}
+	return required
+}
+
+// leadingZeroBits counts the leading zero bits of an event ID hash.
+func leadingZeroBits(id [32]byte) int {
+	zeros := 0
+	for _, b := range id {
+		if b == 0 {
+			zeros += 8
+			continue
+		}
+		zeros += bits.LeadingZeros8(b)
+		break
+	}
+	return zeros
+}
+
+// mineEvent adds a NIP-13 nonce tag to ev so its ID has at least difficulty
+// leading zero bits, spreading the work over every CPU core. The author and
+// timestamp must already be set. Progress is printed to stderr until a
+// nonce is found or ctx is cancelled.
+func mineEvent(ctx context.Context, ev *nostr.Event, difficulty int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	base := ev.Tags.FilterOut([]string{"nonce"})
+	workers := runtime.NumCPU()
+	found := make(chan nostr.Tags, workers)
+	var hashes atomic.Uint64
+
+	for w := 0; w < workers; w++ {
+		go func(nonce uint64) {
+			candidate := *ev
+			tag := nostr.Tag{"nonce", "", strconv.Itoa(difficulty)}
+			candidate.Tags = append(append(nostr.Tags{}, base...), tag)
+
+			for ctx.Err() == nil {
+				for n := 0; n < 10000; n++ {
+					tag[1] = strconv.FormatUint(nonce, 10)
+					if leadingZeroBits(sha256.Sum256(candidate.Serialize())) >= difficulty {
+						found <- candidate.Tags
+						cancel()
+						return
+					}
+					nonce += uint64(workers)
+				}
+				hashes.Add(10000)
+			}
+		}(uint64(w))
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case tags := <-found:
+			ev.Tags = tags
+			ev.ID = ev.GetID()
+			fmt.Fprintf(os.Stderr, "\r%s\n", successStyle.Render(fmt.Sprintf("✓ Mined difficulty %d in %s (%d bits)          ",
+				difficulty, time.Since(start).Round(time.Millisecond), nip13.Difficulty(ev.ID))))
+			return nil
+		case <-ctx.Done():
+			// A worker may have found a nonce just as we were cancelled
+			select {
+			case tags := <-found:
+				ev.Tags = tags
+				ev.ID = ev.GetID()
+				fmt.Fprintln(os.Stderr)
+				return nil
+			default:
+			}
+			fmt.Fprintln(os.Stderr)
+			return fmt.Errorf("proof of work cancelled")
+		case <-ticker.C:
+			elapsed := time.Since(start)
+			done := hashes.Load()
+			rate := float64(done) / elapsed.Seconds()
+			fmt.Fprintf(os.Stderr, "\r  %s %s", infoStyle.Render("⛏"), infoStyle.Render(fmt.Sprintf(
+				"Mining difficulty %d on %d cores: %s hashes (%s/s, ~%s expected)",
+				difficulty, workers, humanCount(float64(done)), humanCount(rate), humanCount(math.Pow(2, float64(difficulty))))))
+		}
+	}
+}
+
+// humanCount formats large numbers as 1.2k, 3.4M and so on.
+func humanCount(n float64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fG", n/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", n/1e3)
+	default:
+		return fmt.Sprintf("%.0f", n)
+	}
+}
+
+// mineWithInterrupt mines ev, letting Ctrl+C cancel the work instead of
+// killing nos.
+func mineWithInterrupt(ev *nostr.Event, difficulty int) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintln(os.Stderr, infoStyle.Render(fmt.Sprintf("Mining proof of work (difficulty %d), press Ctrl+C to cancel...", difficulty)))
+	return mineEvent(ctx, ev, difficulty)
+}
+
+// confirmMining asks whether to spend time mining. In non-interactive use
+// it only says yes when --pow auto was given.
+func confirmMining(question string, auto bool) bool {
+	if auto {
+		return true
+	}
+	if hasStdin() {
+		fmt.Println(infoStyle.Render(question + " Re-run with --pow auto to mine automatically."))
+		return false
+	}
+
+	var confirm bool
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title(question).
+				Affirmative("Yes, mine it").
+				Negative("No").
+				Value(&confirm),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return false
+	}
+	return confirm
+}
+
+// mediaServer is where attachments are uploaded: a Blossom server or a
+// NIP-96 file storage server.
+type mediaServer struct {
+	URL  string `json:"url"`
+	Type string `json:"type"`
+}
+
+func getMediaServer() (mediaServer, error) {
+	var server mediaServer
+	data, err := keyring.Get(appName, mediaServerKey)
+	if err != nil {
+		return server, err
+	}
+	err = json.Unmarshal([]byte(data), &server)
+	return server, err
+}
+
+func storeMediaServer(server mediaServer) error {
+	data, err := json.Marshal(server)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(appName, mediaServerKey, string(data))
+}
+
+// mediaFile is an uploaded attachment, described the way NIP-92 wants it.
+type mediaFile struct {
+	url      string
+	mimeType string
+	sha256   string
+	dim      string
+	blurhash string
+}
+
+// imeta returns the NIP-92 tag describing the file.
+func (f mediaFile) imeta() nostr.Tag {
+	tag := nostr.Tag{"imeta", "url " + f.url}
+	if f.mimeType != "" {
+		tag = append(tag, "m "+f.mimeType)
+	}
+	if f.sha256 != "" {
+		tag = append(tag, "x "+f.sha256)
+	}
+	if f.dim != "" {
+		tag = append(tag, "dim "+f.dim)
+	}
+	if f.blurhash != "" {
+		tag = append(tag, "blurhash "+f.blurhash)
+	}
+	return tag
+}
+
+// attachMedia uploads the files in opts.attach, appends their URLs to the
+// message and records their imeta tags in the returned options.
+func attachMedia(sk string, message string, opts postOptions) (string, postOptions, error) {
+	server, err := getMediaServer()
+	if err != nil {
+		return message, opts, fmt.Errorf("no media server configured, run 'nos media set <url>' first")
+	}
+
+	urls := []string{}
+	for _, path := range opts.attach {
+		fmt.Printf("  %s Uploading %s to %s...\n", infoStyle.Render("→"), filepath.Base(path), server.URL)
+		file, err := uploadMedia(sk, server, path)
+		if err != nil {
+			return message, opts, fmt.Errorf("%s: %v", path, err)
+		}
+		fmt.Printf("  %s %s\n", successStyle.Render("✓"), file.url)
+		urls = append(urls, file.url)
+		opts.media = append(opts.media, file.imeta())
+	}
+	opts.attach = nil
+
+	if message = strings.TrimSpace(message); message != "" {
+		message += "\n\n"
+	}
+	return message + strings.Join(urls, "\n"), opts, nil
+}
+
+// uploadMedia uploads the file at path and describes the result. Whatever
+// the server reports about the stored file wins over what we worked out
+// locally, since NIP-96 servers may transform uploads.
+func uploadMedia(sk string, server mediaServer, path string) (mediaFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mediaFile{}, err
+	}
+
+	hash := sha256.Sum256(data)
+	local := mediaFile{
+		mimeType: mime.TypeByExtension(strings.ToLower(filepath.Ext(path))),
+		sha256:   hex.EncodeToString(hash[:]),
+	}
+	if local.mimeType == "" {
+		local.mimeType = http.DetectContentType(data)
+	}
+	local.mimeType, _, _ = strings.Cut(local.mimeType, ";")
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		bounds := img.Bounds()
+		local.dim = fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy())
+		local.blurhash = blurhash(img)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mediaUploadTimeout)
+	defer cancel()
+
+	var tags nostr.Tags
+	switch server.Type {
+	case "nip96":
+		tags, err = uploadNIP96(ctx, server.URL, sk, path, data, local.mimeType)
+	default:
+		tags, err = uploadBlossom(ctx, server.URL, sk, data, local.sha256, local.mimeType)
+	}
+	if err != nil {
+		return mediaFile{}, err
+	}
+
+	file := local
+	file.url = tags.Find("url").Value()
+	if file.url == "" {
+		return mediaFile{}, fmt.Errorf("server did not return a URL")
+	}
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{{"m", &file.mimeType}, {"x", &file.sha256}, {"dim", &file.dim}, {"blurhash", &file.blurhash}} {
+		if value := tags.Find(field.key).Value(); value != "" {
+			*field.value = value
+		}
+	}
+	return file, nil
+}
+
+// blobDescriptor is a Blossom server's answer to an upload (BUD-02), with
+// the optional NIP-94 tags of BUD-08.
+type blobDescriptor struct {
+	URL    string     `json:"url"`
+	SHA256 string     `json:"sha256"`
+	Size   int64      `json:"size"`
+	Type   string     `json:"type"`
+	NIP94  nostr.Tags `json:"nip94,omitempty"`
+}
+
+// uploadBlossom PUTs data to a Blossom server's /upload endpoint, authorized
+// with a kind 24242 event (BUD-01/02), and returns NIP-94 style tags.
+func uploadBlossom(ctx context.Context, server string, sk string, data []byte, hash string, mimeType string) (nostr.Tags, error) {
+	auth := nostr.Event{
+		Kind:    24242,
+		Content: "Upload " + hash,
+		Tags: nostr.Tags{
+			{"t", "upload"},
+			{"x", hash},
+			{"expiration", strconv.FormatInt(time.Now().Add(mediaUploadTimeout).Unix(), 10)},
+		},
+	}
+	header, err := nostrAuthHeader(sk, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimRight(server, "/")+"/upload", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", header)
+	req.Header.Set("Content-Type", mimeType)
+	req.Header.Set("X-SHA-256", hash)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if reason := resp.Header.Get("X-Reason"); reason != "" {
+			return nil, fmt.Errorf("upload failed: %s (%s)", reason, resp.Status)
+		}
+		return nil, fmt.Errorf("upload failed: %s", resp.Status)
+	}
+
+	var blob blobDescriptor
+	if err := json.NewDecoder(resp.Body).Decode(&blob); err != nil {
+		return nil, fmt.Errorf("invalid response from server: %v", err)
+	}
+
+	tags := nostr.Tags{{"url", blob.URL}, {"x", blob.SHA256}}
+	if blob.Type != "" {
+		tags = append(tags, nostr.Tag{"m", blob.Type})
+	}
+	return append(blob.NIP94, tags...), nil
+}
+
+// nostrAuthHeader signs ev and encodes it for an "Authorization: Nostr"
+// HTTP header.
+func nostrAuthHeader(sk string, ev nostr.Event) (string, error) {
+	if err := signEvent(sk, &ev); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return "", err
+	}
+	return "Nostr " + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// nip96Info is the part of a server's /.well-known/nostr/nip96.json we use.
+type nip96Info struct {
+	APIURL         string `json:"api_url"`
+	DelegatedToURL string `json:"delegated_to_url"`
+}
+
+// nip96APIURL looks up where a NIP-96 server takes uploads, following
+// delegation to another server once.
+func nip96APIURL(ctx context.Context, server string) (string, error) {
+	for range 2 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(server, "/")+"/.well-known/nostr/nip96.json", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+
+		var info nip96Info
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			return "", fmt.Errorf("%s is not a NIP-96 server", server)
+		}
+
+		if info.APIURL != "" {
+			return info.APIURL, nil
+		}
+		if info.DelegatedToURL == "" {
+			break
+		}
+		server = info.DelegatedToURL
+	}
+	return "", fmt.Errorf("%s does not advertise an upload URL", server)
+}
+
+// postNIP96 sends data to a NIP-96 upload endpoint as a multipart form,
+// authorized with a kind 27235 event (NIP-98) that covers the file's hash.
+func postNIP96(ctx context.Context, apiURL string, sk string, path string, data []byte, mimeType string) (*nip96.UploadResponse, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	part.Write(data)
+	form.WriteField("content_type", mimeType)
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	header, err := nostrAuthHeader(sk, nostr.Event{
+		Kind: 27235,
+		Tags: nostr.Tags{
+			{"u", apiURL},
+			{"method", http.MethodPost},
+			{"payload", hex.EncodeToString(hash[:])},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", header)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var resp nip96.UploadResponse
+	decodeErr := json.NewDecoder(res.Body).Decode(&resp)
+	switch {
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		if resp.Message != "" {
+			return nil, fmt.Errorf("upload failed: %s (%s)", resp.Message, res.Status)
+		}
+		return nil, fmt.Errorf("upload failed: %s", res.Status)
+	case decodeErr != nil:
+		return nil, fmt.Errorf("invalid response from server: %v", decodeErr)
+	}
+	return &resp, nil
+}
+
+// uploadNIP96 uploads data to a NIP-96 server, waiting for it to finish
+// processing if needed, and returns the tags of its NIP-94 event.
+func uploadNIP96(ctx context.Context, server string, sk string, path string, data []byte, mimeType string) (nostr.Tags, error) {
+	apiURL, err := nip96APIURL(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := postNIP96(ctx, apiURL, sk, path, data, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	for resp.Nip94Event.Tags.Find("url") == nil && resp.ProcessingURL != "" {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("server is still processing the upload")
+		case <-time.After(mediaProcessingPoll):
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, resp.ProcessingURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(res.Body).Decode(resp)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid response from server: %v", err)
+		}
+		if resp.Status == "error" {
+			return nil, fmt.Errorf("upload failed: %s", resp.Message)
+		}
+	}
+
+	if resp.Status == "error" {
+		return nil, fmt.Errorf("upload failed: %s", resp.Message)
+	}
+	return resp.Nip94Event.Tags, nil
+}
+
+const blurhashChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurhash encodes a small placeholder for img (https://blurha.sh). The
+// image is sampled on a grid of at most 64x64 pixels to keep it quick.
+func blurhash(img image.Image) string {
+	bounds := img.Bounds()
+	width, height := min(bounds.Dx(), 64), min(bounds.Dy(), 64)
+	if width == 0 || height == 0 {
+		return ""
+	}
+
+	// Four components along the longer side, three along the shorter
+	xComponents, yComponents := 4, 3
+	if bounds.Dy() > bounds.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+
+	pixels := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height).RGBA()
+			pixels[y*width+x] = [3]float64{srgbToLinear(r >> 8), srgbToLinear(g >> 8), srgbToLinear(b >> 8)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var sum [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					for c := range sum {
+						sum[c] += basis * pixels[y*width+x][c]
+					}
+				}
+			}
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{sum[0] * scale, sum[1] * scale, sum[2] * scale})
+		}
+	}
+
+	hash := encode83((xComponents-1)+(yComponents-1)*9, 1)
+
+	maximum := 1.0
+	ac := factors[1:]
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantised := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		hash += encode83(quantised, 1)
+	} else {
+		hash += encode83(0, 1)
+	}
+
+	dc := factors[0]
+	hash += encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+
+	for _, f := range ac {
+		quant := func(v float64) int {
+			signed := math.Copysign(math.Pow(math.Abs(v/maximum), 0.5), v)
+			return int(max(0, min(18, math.Floor(signed*9+9.5))))
+		}
+		hash += encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+	return hash
+}
+
+func encode83(value int, length int) string {
+	digits := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		digits[i] = blurhashChars[value%83]
+		value /= 83
+	}
+	return string(digits)
+}
+
+func srgbToLinear(value uint32) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := max(0, min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func handleMediaCommand() {
+	command := "show"
+	if len(os.Args) >= 3 {
+		command = os.Args[2]
+	}
+
+	switch command {
+	case "show":
+		server, err := getMediaServer()
+		if err != nil {
+			fmt.Println(infoStyle.Render("No media server configured. Set one with 'nos media set <url>'."))
+			return
+		}
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Media server: %s (%s)", server.URL, server.Type)))
+	case "set":
+		if len(os.Args) < 4 {
+			showMediaUsage()
+			os.Exit(exitUsage)
+		}
+		setMediaServer(os.Args[3], os.Args[4:])
+	case "clear":
+		err := keyring.Delete(appName, mediaServerKey)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			fail(exitFailed, err.Error())
+		}
+		fmt.Println(successStyle.Render("✓ Media server cleared"))
+	case "upload":
+		if len(os.Args) < 4 {
+			showMediaUsage()
+			os.Exit(exitUsage)
+		}
+		sk, err := loadSecretKey()
+		if err != nil {
+			fail(exitNoSetup, err.Error())
+		}
+		message, _, err := attachMedia(sk, "", postOptions{attach: os.Args[3:]})
+		if err != nil {
+			fail(exitFailed, err.Error())
+		}
+		fmt.Println(message)
+	default:
+		showMediaUsage()
+		os.Exit(exitUsage)
+	}
+}
+
+// setMediaServer stores the server to upload attachments to. Unless told
+// otherwise it is treated as NIP-96 if it serves a nip96.json, and as
+// Blossom otherwise.
+func setMediaServer(url string, flags []string) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		fail(exitUsage, "media server URL must start with https:// or http://")
+	}
+	server := mediaServer{URL: strings.TrimRight(url, "/")}
+
+	for _, flag := range flags {
+		switch flag {
+		case "--blossom":
+			server.Type = "blossom"
+		case "--nip96":
+			server.Type = "nip96"
+		default:
+			fail(exitUsage, "unknown option "+flag)
+		}
+	}
+
+	if server.Type == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+		server.Type = "blossom"
+		if _, err := nip96APIURL(ctx, server.URL); err == nil {
+			server.Type = "nip96"
+		}
+	}
+
+	if err := storeMediaServer(server); err != nil {
+		fail(exitFailed, "saving media server: "+err.Error())
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Uploading attachments to %s (%s)", server.URL, server.Type)))
+}
+
+func showMediaUsage() {
+	fmt.Println(titleStyle.Render("Media Uploads"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos media                  - Show the configured media server"))
+	fmt.Println(infoStyle.Render("  nos media set <url>        - Upload to a Blossom or NIP-96 server"))
+	fmt.Println(infoStyle.Render("  nos media clear            - Forget the media server"))
+	fmt.Println(infoStyle.Render("  nos media upload <file>... - Upload files and print their URLs"))
+	fmt.Println(infoStyle.Render("\nAdd --blossom or --nip96 to 'set' to skip detecting the server type."))
+	fmt.Println(infoStyle.Render("Attach files to a post with: nos --attach photo.jpg \"message\""))
+}
+
+// readEvents decodes a stream of JSON events from the file at path, or
+// from stdin when path is empty or "-".
+func readEvents(path string) ([]nostr.Event, error) {
+	input := io.Reader(os.Stdin)
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	events := []nostr.Event{}
+	dec := json.NewDecoder(input)
+	for {
+		var ev nostr.Event
+		err := dec.Decode(&ev)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid event JSON: %v", err)
+		}
+		events = append(events, ev)
+	}
+}
+
+// handleSign signs unsigned event templates without touching the network,
+// so it can run on an offline machine.
+func handleSign() {
+	path := ""
+	if len(os.Args) >= 3 {
+		path = os.Args[2]
+	}
+	if (path == "" || path == "-") && !hasStdin() {
+		showSignUsage()
+		os.Exit(exitUsage)
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+	pub, _ := nostr.GetPublicKey(sk)
+
+	events, err := readEvents(path)
+	if err != nil {
+		fail(exitFailed, "reading event template: "+err.Error())
+	}
+
+	for _, ev := range events {
+		if ev.PubKey != "" && ev.PubKey != pub {
+			fail(exitUsage, "template is for pubkey "+ev.PubKey+", not your key")
+		}
+
+		ev.ID, ev.Sig = "", ""
+		if err := signEvent(sk, &ev); err != nil {
+			fail(exitFailed, "signing: "+err.Error())
+		}
+		printEventJSON(ev)
+	}
+}
+
+// handlePublish broadcasts events that were signed elsewhere, as they are.
+func handlePublish() {
+	path := ""
+	if len(os.Args) >= 3 {
+		path = os.Args[2]
+	}
+	if (path == "" || path == "-") && !hasStdin() {
+		showSignUsage()
+		os.Exit(exitUsage)
+	}
+
+	events, err := readEvents(path)
+	if err != nil {
+		fail(exitFailed, "reading event: "+err.Error())
+	}
+
+	// Check every event before sending any of them
+	for _, ev := range events {
+		if !ev.CheckID() {
+			fail(exitUsage, "event "+ev.ID+" has an ID that doesn't match its contents")
+		}
+		if ok, err := ev.CheckSignature(); !ok || err != nil {
+			fail(exitUsage, "event "+ev.ID+" has an invalid signature")
+		}
+	}
+
+	var codes []int
+	for _, ev := range events {
+		showEventDetails(ev)
+		results, err := publishEventResults(ev, getActiveRelays())
+		codes = append(codes, publishExitCode(results, err, postOptions{}))
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error publishing: " + err.Error()))
+			continue
+		}
+		fmt.Println(successStyle.Render("✓ Published " + ev.ID))
+	}
+	os.Exit(combineExitCodes(codes))
+}
+
+func showSignUsage() {
+	fmt.Println(titleStyle.Render("Offline Signing"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos sign [file]            - Sign event templates from a file or stdin"))
+	fmt.Println(infoStyle.Render("  nos publish [file]         - Broadcast signed events from a file or stdin"))
+	fmt.Println(infoStyle.Render("  nos --dry-run <message>    - Print a signed post without publishing"))
+	fmt.Println(infoStyle.Render("\nExample:"))
+	fmt.Println(infoStyle.Render("  echo '{\"kind\":1,\"content\":\"hi\"}' | nos sign > signed.json"))
+	fmt.Println(infoStyle.Render("  nos publish signed.json"))
+}
+
+// parseTagArg turns "name=value;value2" into a tag. A name on its own
+// makes a tag without values. Inside a value, \; stands for a ; and \\ for
+// a backslash.
+func parseTagArg(arg string) (nostr.Tag, error) {
+	name, values, hasValues := strings.Cut(arg, "=")
+	if name == "" {
+		return nil, fmt.Errorf("invalid tag %q, expected name=value", arg)
+	}
+	tag := nostr.Tag{name}
+	if !hasValues {
+		return tag, nil
+	}
+
+	var value strings.Builder
+	for i := 0; i < len(values); i++ {
+		switch {
+		case values[i] == '\\' && i+1 < len(values) && (values[i+1] == ';' || values[i+1] == '\\'):
+			i++
+			value.WriteByte(values[i])
+		case values[i] == ';':
+			tag = append(tag, value.String())
+			value.Reset()
+		default:
+			value.WriteByte(values[i])
+		}
+	}
+	return append(tag, value.String()), nil
+}
+
+// handleEvent builds, signs and publishes an event of any kind, for
+// everything nos doesn't have a dedicated command for.
+func handleEvent() {
+	kind := -1
+	content := ""
+	hasContent := false
+	tags := nostr.Tags{}
+	var opts postOptions
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if !strings.HasPrefix(name, "-") {
+			fmt.Println(errorStyle.Render("Error: unexpected argument " + args[i]))
+			showEventUsage()
+			os.Exit(exitUsage)
+		}
+		if name != "--dry-run" && !hasValue {
+			if i+1 >= len(args) {
+				fail(exitUsage, name+" needs a value")
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--kind", "-k":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 65535 {
+				fail(exitUsage, "--kind must be a number between 0 and 65535")
+			}
+			kind = n
+		case "--tag", "-t":
+			tag, err := parseTagArg(value)
+			if err != nil {
+				fail(exitUsage, err.Error())
+			}
+			tags = append(tags, tag)
+		case "--content", "-c":
+			content = value
+			hasContent = true
+		case "--pow":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 256 {
+				fail(exitUsage, "--pow must be a difficulty between 0 and 256")
+			}
+			opts.powDifficulty = n
+		case "--dry-run":
+			opts.dryRun = true
+		default:
+			fmt.Println(errorStyle.Render("Error: unknown option " + name))
+			showEventUsage()
+			os.Exit(exitUsage)
+		}
+	}
+
+	if kind == -1 {
+		showEventUsage()
+		os.Exit(exitUsage)
+	}
+	if (!hasContent || content == "-") && hasStdin() {
+		var err error
+		content, err = readStdin()
+		if err != nil {
+			fail(exitFailed, "reading from stdin: "+err.Error())
+		}
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+
+	ev := nostr.Event{Kind: kind, Tags: tags, Content: content}
+	if opts.powDifficulty > 0 {
+		err = prepareEvent(sk, &ev)
+		if err == nil {
+			err = mineWithInterrupt(&ev, opts.powDifficulty)
+		}
+		if err != nil {
+			fail(exitFailed, err.Error())
+		}
+	}
+
+	if opts.dryRun {
+		err = signEvent(sk, &ev)
+		if err != nil {
+			fail(exitFailed, "signing: "+err.Error())
+		}
+		printEventJSON(ev)
+		return
+	}
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Publishing kind %d event...", kind)))
+	results, err := signAndPublish(sk, ev)
+	if err != nil {
+		fail(exitFailed, "publishing: "+err.Error())
+	}
+
+	fmt.Println(successStyle.Render("✓ Event published!"))
+	os.Exit(publishExitCode(results, nil, postOptions{}))
+}
+
+func showEventUsage() {
+	fmt.Println(titleStyle.Render("Publish Any Event"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos event -k <kind> [-t name=value;value2]... [-c <content>]"))
+	fmt.Println(infoStyle.Render("\nOptions:"))
+	fmt.Println(infoStyle.Render("  -k, --kind <n>             - Event kind (required)"))
+	fmt.Println(infoStyle.Render("  -t, --tag <name=value>     - Add a tag; separate extra values with ; (write \\; for a literal ;)"))
+	fmt.Println(infoStyle.Render("  -c, --content <text>       - Event content, read from stdin if omitted"))
+	fmt.Println(infoStyle.Render("  --pow <n>                  - Mine proof of work of difficulty n"))
+	fmt.Println(infoStyle.Render("  --dry-run                  - Print the signed event without publishing"))
+	fmt.Println(infoStyle.Render("\nExample:"))
+	fmt.Println(infoStyle.Render("  nos event -k 1985 -t L=com.example -t \"l=spam;com.example\" -t e=<id> -c \"\""))
+}
+
+// parseSince turns "30d", "12h" or a date into a timestamp in the past.
+func parseSince(value string) (nostr.Timestamp, error) {
+	now := time.Now()
+	if m := relativeDaysRegex.FindStringSubmatch(value); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		return nostr.Timestamp(now.AddDate(0, 0, -days).Unix()), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return nostr.Timestamp(now.Add(-d).Unix()), nil
+	}
+
+	at, err := parseScheduleTime(value, now)
+	if err != nil {
+		return 0, err
+	}
+	if at.After(now) {
+		return 0, fmt.Errorf("%s is in the future", value)
+	}
+	return nostr.Timestamp(at.Unix()), nil
+}
+
+// parsePubkeyRef accepts a hex pubkey, npub or nprofile, with an optional
+// nostr: prefix, and returns the pubkey along with any relay hints.
+func parsePubkeyRef(ref string) (string, []string, error) {
+	ref = strings.TrimPrefix(ref, "nostr:")
+	if nostr.IsValidPublicKey(ref) {
+		return ref, nil, nil
+	}
+
+	prefix, value, err := nip19.Decode(ref)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid pubkey %q: %v", ref, err)
+	}
+	switch prefix {
+	case "npub":
+		return value.(string), nil, nil
+	case "nprofile":
+		profile := value.(nostr.ProfilePointer)
+		return profile.PublicKey, profile.Relays, nil
+	}
+	return "", nil, fmt.Errorf("expected an npub or nprofile, got %s", prefix)
+}
+
+// eventCopy is an event found on relays, with the relays that have it.
+type eventCopy struct {
+	ev     *nostr.Event
+	relays map[string]bool
+}
+
+// collectEvents queries the relays in parallel and groups the valid events
+// they return by ID, remembering which relays (by normalized URL) have
+// each one.
+func collectEvents(relays []string, filters ...nostr.Filter) map[string]*eventCopy {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	type queryResult struct {
+		url    string
+		events []*nostr.Event
+	}
+
+	results := make(chan queryResult, len(relays))
+	for _, url := range relays {
+		go func() {
+			events, _ := queryRelay(ctx, url, filters...)
+			results <- queryResult{url, events}
+		}()
+	}
+
+	found := map[string]*eventCopy{}
+	for range relays {
+		res := <-results
+		for _, ev := range res.events {
+			if !validFeedEvent(ev, filters) {
+				continue
+			}
+			c, ok := found[ev.ID]
+			if !ok {
+				c = &eventCopy{ev: ev, relays: map[string]bool{}}
+				found[ev.ID] = c
+			}
+			c.relays[nostr.NormalizeURL(res.url)] = true
+		}
+	}
+	return found
+}
+
+// rebroadcastEvents sends each relay the events it is missing over a single
+// connection per relay, printing one summary line per relay. Relays get to
+// log in like any other publish, the whole broadcast is bounded by
+// broadcastTimeout, and failures are queued in the outbox. It returns how
+// many copies were delivered.
+func rebroadcastEvents(missing map[string][]*nostr.Event) int {
+	type relaySummary struct {
+		url    string
+		sent   int
+		failed []relayResult
+		events []*nostr.Event
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastTimeout)
+	defer cancel()
+
+	summaries := make(chan relaySummary, len(missing))
+	for url, events := range missing {
+		go func() {
+			summary := relaySummary{url: url}
+			start := time.Now()
+
+			connCtx, cancel := context.WithTimeout(ctx, relayConnectTimeout)
+			relay, err := nostr.RelayConnect(connCtx, url)
+			cancel()
+			if err != nil {
+				for _, ev := range events {
+					summary.failed = append(summary.failed, relayResult{url: url, err: err, stage: "connection", elapsed: time.Since(start)})
+					summary.events = append(summary.events, ev)
+				}
+				summaries <- summary
+				return
+			}
+			defer relay.Close()
+
+			for _, ev := range events {
+				_, stage, err := publishWithAuth(ctx, relay, url, *ev)
+				if err != nil {
+					summary.failed = append(summary.failed, relayResult{url: url, err: err, stage: stage, elapsed: time.Since(start)})
+					summary.events = append(summary.events, ev)
+					continue
+				}
+				summary.sent++
+			}
+			summaries <- summary
+		}()
+	}
+
+	delivered := 0
+	retry := map[string][]relayResult{}
+	events := map[string]*nostr.Event{}
+	for range missing {
+		summary := <-summaries
+		total := summary.sent + len(summary.failed)
+		delivered += summary.sent
+
+		if len(summary.failed) == 0 {
+			fmt.Printf("  %s %s %s\n", infoStyle.Render("→"), summary.url, successStyle.Render(fmt.Sprintf("✓ sent %d/%d", summary.sent, total)))
+			continue
+		}
+		fmt.Printf("  %s %s %s\n", infoStyle.Render("→"), summary.url,
+			errorStyle.Render(fmt.Sprintf("sent %d/%d, %s", summary.sent, total, summary.failed[0].describe())))
+
+		for i, res := range summary.failed {
+			ev := summary.events[i]
+			if shouldRetry(res.err) {
+				retry[ev.ID] = append(retry[ev.ID], res)
+				events[ev.ID] = ev
+			}
+		}
+	}
+
+	if len(retry) > 0 {
+		for id, failed := range retry {
+			queueOutbox(*events[id], failed, nil)
+		}
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Saved %d events to the outbox to retry later ('nos outbox status').", len(retry))))
+	}
+	return delivered
+}
+
+// handleBroadcast copies existing events to every active relay that doesn't
+// have them yet, either the events named on the command line or everything
+// an author published since a given time.
+func handleBroadcast() {
+	var refs []string
+	var author, since string
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--author" || args[i] == "-a") && i+1 < len(args):
+			author = args[i+1]
+			i++
+		case (args[i] == "--since" || args[i] == "-s") && i+1 < len(args):
+			since = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "-"):
+			showBroadcastUsage()
+			os.Exit(exitUsage)
+		default:
+			refs = append(refs, args[i])
+		}
+	}
+
+	if len(refs) == 0 && author == "" && since == "" {
+		showBroadcastUsage()
+		os.Exit(exitUsage)
+	}
+	if len(refs) > 0 && (author != "" || since != "") {
+		fail(exitUsage, "give either events or --author/--since, not both")
+	}
+
+	active := getActiveRelays()
+	var copies map[string]*eventCopy
+
+	if len(refs) > 0 {
+		fetched := []*nostr.Event{}
+		ids := []string{}
+		for _, ref := range refs {
+			ptr, err := parseRef(ref)
+			if err != nil {
+				fail(exitUsage, err.Error())
+			}
+			ev, _, err := fetchEvent(ptr)
+			if err != nil {
+				fmt.Println(errorStyle.Render("Error: " + err.Error()))
+				continue
+			}
+			fetched = append(fetched, ev)
+			ids = append(ids, ev.ID)
+		}
+		if len(fetched) == 0 {
+			os.Exit(exitFailed)
+		}
+
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Checking %d relays for %d events...", len(active), len(fetched))))
+		copies = collectEvents(active, nostr.Filter{IDs: ids})
+		for _, ev := range fetched {
+			if _, ok := copies[ev.ID]; !ok {
+				copies[ev.ID] = &eventCopy{ev: ev, relays: map[string]bool{}}
+			}
+		}
+	} else {
+		var pub string
+		var hints []string
+		var err error
+		if author != "" {
+			pub, hints, err = parsePubkeyRef(author)
+			if err != nil {
+				fail(exitUsage, err.Error())
+			}
+		} else {
+			var sk string
+			sk, err = loadSecretKey()
+			if err != nil {
+				fail(exitNoSetup, err.Error())
+			}
+			pub, _ = nostr.GetPublicKey(sk)
+		}
+
+		filter := nostr.Filter{Authors: []string{pub}}
+		if since != "" {
+			ts, err := parseSince(since)
+			if err != nil {
+				fail(exitUsage, "invalid --since: "+err.Error())
+			}
+			filter.Since = &ts
+		}
+
+		relays := mergeRelays(hints, active)
+		npub, _ := nip19.EncodePublicKey(pub)
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Fetching events by %s from %d relays...", npub, len(relays))))
+		copies = collectEvents(relays, filter)
+	}
+
+	if len(copies) == 0 {
+		fmt.Println(infoStyle.Render("No events found."))
+		return
+	}
+
+	// Oldest first, so relays end up with the latest version of
+	// replaceable events
+	events := make([]*eventCopy, 0, len(copies))
+	for _, c := range copies {
+		events = append(events, c)
+	}
+	slices.SortFunc(events, func(a, b *eventCopy) int {
+		return int(a.ev.CreatedAt - b.ev.CreatedAt)
+	})
+
+	missing := map[string][]*nostr.Event{}
+	copiesNeeded := 0
+	for _, c := range events {
+		for _, url := range active {
+			if !c.relays[nostr.NormalizeURL(url)] {
+				missing[url] = append(missing[url], c.ev)
+				copiesNeeded++
+			}
+		}
+	}
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Found %d events.", len(events))))
+	if copiesNeeded == 0 {
+		fmt.Println(successStyle.Render("✓ Every relay already has them"))
+		return
+	}
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Sending %d missing copies to %d relays...", copiesNeeded, len(missing))))
+	delivered := rebroadcastEvents(missing)
+
+	fmt.Println()
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Rebroadcast %d/%d copies", delivered, copiesNeeded)))
+}
+
+func showBroadcastUsage() {
+	fmt.Println(titleStyle.Render("Rebroadcast Events"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos broadcast <note|nevent|naddr|id>...  - Copy events to your relays"))
+	fmt.Println(infoStyle.Render("  nos broadcast --since 30d                - Copy your own recent events"))
+	fmt.Println(infoStyle.Render("  nos broadcast --author <npub> [--since <time>]"))
+	fmt.Println(infoStyle.Render("\nOnly relays that don't have an event yet are sent a copy."))
+}
+
+// feedKinds are the event kinds shown in the home timeline: notes, reposts
+// and long-form articles.
+var feedKinds = []int{nostr.KindTextNote, nostr.KindRepost, nostr.KindArticle}
+
+// cachedProfile is the part of a kind 0 profile nos displays.
+type cachedProfile struct {
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+	FetchedAt   int64  `json:"fetched_at"`
+}
+
+// getProfiles returns the profiles of the given pubkeys, fetching the ones
+// that aren't cached (or whose cache is stale) from the active relays.
+// Pubkeys without a profile are remembered too, so they aren't looked up
+// again until the cache expires.
+func getProfiles(pubs []string) map[string]cachedProfile {
+	cache := map[string]cachedProfile{}
+	loadData(profilesFile, &cache)
+
+	stale := []string{}
+	for _, pub := range pubs {
+		cached, ok := cache[pub]
+		if ok && time.Since(time.Unix(cached.FetchedAt, 0)) < profileCacheTTL {
+			continue
+		}
+		if !slices.Contains(stale, pub) {
+			stale = append(stale, pub)
+		}
+	}
+	if len(stale) == 0 {
+		return cache
+	}
+
+	now := time.Now().Unix()
+	for _, pub := range stale {
+		cached := cache[pub]
+		cached.FetchedAt = now
+		cache[pub] = cached
+	}
+
+	filter := nostr.Filter{Kinds: []int{nostr.KindProfileMetadata}, Authors: stale}
+	for _, c := range collectEvents(getActiveRelays(), filter) {
+		cached := cache[c.ev.PubKey]
+		if int64(c.ev.CreatedAt) < cached.CreatedAt {
+			continue
+		}
+		var meta struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"display_name"`
+		}
+		if json.Unmarshal([]byte(c.ev.Content), &meta) != nil {
+			continue
+		}
+		cache[c.ev.PubKey] = cachedProfile{
+			Name:        meta.Name,
+			DisplayName: meta.DisplayName,
+			CreatedAt:   int64(c.ev.CreatedAt),
+			FetchedAt:   now,
+		}
+	}
+
+	saveData(profilesFile, cache)
+	return cache
+}
+
+// displayName is how a pubkey is shown: its profile's display name or name,
+// or a shortened npub.
+func displayName(pub string, profiles map[string]cachedProfile) string {
+	p := profiles[pub]
+	if name := truncate(p.DisplayName, 40); name != "" {
+		return name
+	}
+	if name := truncate(p.Name, 40); name != "" {
+		return name
+	}
+	npub, _ := nip19.EncodePublicKey(pub)
+	return npub[:16] + "..."
+}
+
+// loadFollows returns the pubkeys in the newest kind 3 contact list of pub
+// found on the active relays.
+func loadFollows(pub string) ([]string, error) {
+	var latest *nostr.Event
+	filter := nostr.Filter{Kinds: []int{nostr.KindFollowList}, Authors: []string{pub}}
+	for _, c := range collectEvents(getActiveRelays(), filter) {
+		if latest == nil || c.ev.CreatedAt > latest.CreatedAt {
+			latest = c.ev
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no contact list found on your relays")
+	}
+
+	follows := []string{}
+	for _, tag := range latest.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && nostr.IsValidPublicKey(tag[1]) && !slices.Contains(follows, tag[1]) {
+			follows = append(follows, tag[1])
+		}
+	}
+	return follows, nil
+}
+
+// repostedEvent returns the event embedded in a repost, if it carries a
+// valid one.
+func repostedEvent(ev *nostr.Event) *nostr.Event {
+	var orig nostr.Event
+	if json.Unmarshal([]byte(ev.Content), &orig) != nil || !orig.CheckID() {
+		return nil
+	}
+	if ok, _ := orig.CheckSignature(); !ok {
+		return nil
+	}
+	return &orig
+}
+
+// feedAuthors lists the pubkeys whose names are needed to show events,
+// including the authors of reposted events.
+func feedAuthors(events []*nostr.Event) []string {
+	pubs := []string{}
+	for _, ev := range events {
+		pubs = append(pubs, ev.PubKey)
+		if ev.Kind == nostr.KindRepost {
+			if orig := repostedEvent(ev); orig != nil {
+				pubs = append(pubs, orig.PubKey)
+			}
+		}
+	}
+	return pubs
+}
+
+// showFeedEvent prints one timeline entry: who posted it and when, the
+// note, repost or article itself, and a reference for replying to it.
+func showFeedEvent(ev *nostr.Event, profiles map[string]cachedProfile) {
+	header := displayName(ev.PubKey, profiles)
+	body := ev.Content
+	ref, _ := nip19.EncodeNote(ev.ID)
+
+	switch ev.Kind {
+	case nostr.KindRepost:
+		if orig := repostedEvent(ev); orig != nil {
+			header += " reposted " + displayName(orig.PubKey, profiles)
+			body = orig.Content
+			ref, _ = nip19.EncodeNote(orig.ID)
+		} else if tag := ev.Tags.Find("e"); tag != nil {
+			header += " reposted a note"
+			body = ""
+			ref, _ = nip19.EncodeNote(tag[1])
+		}
+	case nostr.KindArticle:
+		header += " published an article"
+		body = ""
+		if tag := ev.Tags.Find("title"); tag != nil {
+			body = tag[1]
+		}
+		if tag := ev.Tags.Find("summary"); tag != nil {
+			body += "\n" + tag[1]
+		}
+		ref, _ = nip19.EncodeEntity(ev.PubKey, ev.Kind, ev.Tags.GetD(), nil)
+	}
+
+	timestamp := ev.CreatedAt.Time().Format("2006-01-02 15:04")
+	fmt.Printf("%s %s\n", successStyle.Render(header), infoStyle.Render("· "+timestamp))
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		fmt.Println("  " + line)
+	}
+	fmt.Println(infoStyle.Render("  " + ref))
+	fmt.Println()
+}
+
+// validFeedEvent reports whether ev matches one of the feed filters and is
+// correctly signed.
+func validFeedEvent(ev *nostr.Event, filters nostr.Filters) bool {
+	if !filters.Match(ev) || !ev.CheckID() {
+		return false
+	}
+	ok, _ := ev.CheckSignature()
+	return ok
+}
+
+// tailRelay streams new events matching filters from one relay until ctx is
+// cancelled, reconnecting after a pause whenever the connection drops.
+func tailRelay(ctx context.Context, url string, filters nostr.Filters, out chan<- *nostr.Event) {
+	for {
+		err := streamRelay(ctx, url, filters, out)
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Println(errorStyle.Render(fmt.Sprintf("%s: %v, reconnecting in %s", url, err, feedReconnectDelay)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(feedReconnectDelay):
+		}
+
+		// Don't ask for what was already streamed before the drop again
+		now := nostr.Now()
+		filters = slices.Clone(filters)
+		for i := range filters {
+			filters[i].Since = &now
+		}
+	}
+}
+
+// streamRelay subscribes to filters on one relay and passes on every event
+// it sends until ctx is cancelled or the relay goes away. It logs in once
+// if the relay requires it and we allow it.
+func streamRelay(ctx context.Context, url string, filters nostr.Filters, out chan<- *nostr.Event) error {
+	connCtx, cancel := context.WithTimeout(ctx, relayConnectTimeout)
+	relay, err := nostr.RelayConnect(connCtx, url)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer relay.Close()
+
+	authenticated := false
+	for {
+		sub, err := relay.Subscribe(ctx, filters)
+		if err != nil {
+			return err
+		}
+
+	stream:
+		for {
+			select {
+			case ev, ok := <-sub.Events:
+				if !ok {
+					return fmt.Errorf("subscription ended")
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return nil
+				}
+			case reason := <-sub.ClosedReason:
+				err := newRelayError(reason)
+				if relayPrefix(err) != reasonAuthRequired || authenticated || !authAllowed(url) {
+					return err
+				}
+				// Drop the closed subscription before asking again
+				sub.Unsub()
+				if err := authenticateRelay(ctx, relay); err != nil {
+					return err
+				}
+				authenticated = true
+				break stream
+			case <-relay.Context().Done():
+				return fmt.Errorf("connection lost")
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// authorFilters copies filter once for every feedAuthorsPerFilter authors,
+// so large contact lists don't go over the relays' filter size limits.
+func authorFilters(filter nostr.Filter, authors []string) nostr.Filters {
+	filters := nostr.Filters{}
+	for chunk := range slices.Chunk(authors, feedAuthorsPerFilter) {
+		f := filter
+		f.Authors = chunk
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+// handleFeed shows recent notes, reposts and articles from the people in
+// our contact list, oldest first, and with --follow keeps printing new ones
+// as relays send them.
+func handleFeed() {
+	limit := defaultFeedLimit
+	var since string
+	follow := false
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--since" || args[i] == "-s") && i+1 < len(args):
+			since = args[i+1]
+			i++
+		case (args[i] == "--limit" || args[i] == "-n") && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fail(exitUsage, "--limit must be a positive number")
+			}
+			limit = n
+			i++
+		case args[i] == "--follow" || args[i] == "-f":
+			follow = true
+		default:
+			showFeedUsage()
+			os.Exit(exitUsage)
+		}
+	}
+
+	sk, err := loadSecretKey()
+	if err != nil {
+		fail(exitNoSetup, err.Error())
+	}
+	pub, _ := nostr.GetPublicKey(sk)
+
+	fmt.Println(infoStyle.Render("Loading your contact list..."))
+	follows, err := loadFollows(pub)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+	if len(follows) == 0 {
+		fmt.Println(infoStyle.Render("You don't follow anyone yet."))
+		return
+	}
+
+	filter := nostr.Filter{Kinds: feedKinds, Limit: limit}
+	if since != "" {
+		ts, err := parseSince(since)
+		if err != nil {
+			fail(exitUsage, "invalid --since: "+err.Error())
+		}
+		filter.Since = &ts
+	}
+	filters := authorFilters(filter, follows)
+
+	relays := getActiveRelays()
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Fetching posts from %d people on %d relays...", len(follows), len(relays))))
+	fmt.Println()
+
+	// collectEvents already drops duplicates and bad signatures
+	events := []*nostr.Event{}
+	for _, c := range collectEvents(relays, filters...) {
+		events = append(events, c.ev)
+	}
+	slices.SortFunc(events, func(a, b *nostr.Event) int {
+		return int(b.CreatedAt - a.CreatedAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	slices.Reverse(events)
+
+	profiles := getProfiles(feedAuthors(events))
+	for _, ev := range events {
+		showFeedEvent(ev, profiles)
+	}
+	if len(events) == 0 {
+		fmt.Println(infoStyle.Render("Nothing new from the people you follow."))
+	}
+	if !follow {
+		return
+	}
+
+	fmt.Println(infoStyle.Render("Waiting for new posts. Press Ctrl+C to stop."))
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	seen := make(map[string]bool)
+	for _, ev := range events {
+		seen[ev.ID] = true
+	}
+	now := nostr.Now()
+	live := authorFilters(nostr.Filter{Kinds: feedKinds, Since: &now}, follows)
+
+	incoming := make(chan *nostr.Event)
+	for _, url := range relays {
+		go tailRelay(ctx, url, live, incoming)
+	}
+	for {
+		select {
+		case ev := <-incoming:
+			if seen[ev.ID] || !validFeedEvent(ev, live) {
+				continue
+			}
+			seen[ev.ID] = true
+			showFeedEvent(ev, getProfiles(feedAuthors([]*nostr.Event{ev})))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func showFeedUsage() {
+	fmt.Println(titleStyle.Render("Home Feed"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos feed [--since <time>] [--limit <n>] [--follow]"))
+	fmt.Println(infoStyle.Render("\nOptions:"))
+	fmt.Println(infoStyle.Render("  -s, --since <30d|12h|date> - Only show posts since then"))
+	fmt.Println(infoStyle.Render(fmt.Sprintf("  -n, --limit <n>            - Show at most n posts (default %d)", defaultFeedLimit)))
+	fmt.Println(infoStyle.Render("  -f, --follow               - Keep printing new posts as they arrive"))
+}
+
+// threadParent returns what ev replies to per NIP-10, as an event ID or an
+// "kind:pubkey:d" address, along with the tag's relay hint. It prefers the
+// "reply" marker, then "root" for direct replies to the root, then the last
+// unmarked e tag for clients that don't add markers. Top-level events have
+// no parent.
+func threadParent(ev *nostr.Event) (string, string) {
+	var reply, root, unmarked nostr.Tag
+	for _, tag := range ev.Tags {
+		if len(tag) < 2 || (tag[0] != "e" && tag[0] != "a") {
+			continue
+		}
+		marker := ""
+		if len(tag) >= 4 {
+			marker = tag[3]
+		}
+		switch {
+		case marker == "reply" && reply == nil:
+			reply = tag
+		case marker == "root" && root == nil:
+			root = tag
+		case marker == "" && tag[0] == "e":
+			unmarked = tag
+		}
+	}
+
+	for _, tag := range []nostr.Tag{reply, root, unmarked} {
+		if tag == nil {
+			continue
+		}
+		hint := ""
+		if len(tag) >= 3 {
+			hint = tag[2]
+		}
+		return tag[1], hint
+	}
+	return "", ""
+}
+
+// threadKeys are the references other events can use for ev: its ID, and
+// its address if it is addressable.
+func threadKeys(ev *nostr.Event) []string {
+	keys := []string{ev.ID}
+	if nostr.IsAddressableKind(ev.Kind) {
+		keys = append(keys, fmt.Sprintf("%d:%s:%s", ev.Kind, ev.PubKey, ev.Tags.GetD()))
+	}
+	return keys
+}
+
+// eventRef is the bech32 reference shown for an event: its naddr if it is
+// addressable, its note1 otherwise.
+func eventRef(ev *nostr.Event) string {
+	if nostr.IsAddressableKind(ev.Kind) {
+		ref, _ := nip19.EncodeEntity(ev.PubKey, ev.Kind, ev.Tags.GetD(), nil)
+		return ref
+	}
+	ref, _ := nip19.EncodeNote(ev.ID)
+	return ref
+}
+
+// threadPointer turns a reference from threadParent into a pointer that
+// fetchEvent can look up.
+func threadPointer(ref string, relays []string) (nostr.Pointer, error) {
+	if nostr.IsValid32ByteHex(ref) {
+		return nostr.EventPointer{ID: ref, Relays: relays}, nil
+	}
+	ptr, err := nostr.EntityPointerFromTag(nostr.Tag{"a", ref})
+	if err != nil {
+		return nil, err
+	}
+	ptr.Relays = relays
+	return ptr, nil
+}
+
+// threadNode is an event in a conversation tree.
+type threadNode struct {
+	ev       *nostr.Event
+	children []*threadNode
+}
+
+// threadStats counts the reactions and reposts of each event in a thread.
+type threadStats struct {
+	reactions map[string]int
+	reposts   map[string]int
+}
+
+// collectThreadStats counts likes and reposts of the given events. Dislikes
+// ("-" reactions) aren't counted.
+func collectThreadStats(relays []string, ids []string) threadStats {
+	stats := threadStats{reactions: map[string]int{}, reposts: map[string]int{}}
+	filter := nostr.Filter{
+		Kinds: []int{nostr.KindReaction, nostr.KindRepost, nostr.KindGenericRepost},
+		Tags:  nostr.TagMap{"e": ids},
+	}
+	for _, c := range collectEvents(relays, filter) {
+		// NIP-25 puts the reacted-to event last
+		var target string
+		for _, tag := range c.ev.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				target = tag[1]
+			}
+		}
+		switch {
+		case c.ev.Kind != nostr.KindReaction:
+			stats.reposts[target]++
+		case c.ev.Content != "-":
+			stats.reactions[target]++
+		}
+	}
+	return stats
+}
+
+// showThreadNode prints an event and its replies, indented by depth. The
+// event that was asked for is highlighted.
+func showThreadNode(node *threadNode, depth int, target string, stats threadStats, profiles map[string]cachedProfile) {
+	indent := strings.Repeat("  ", min(depth, 10))
+	ev := node.ev
+
+	header := displayName(ev.PubKey, profiles)
+	if ev.ID == target {
+		header = "▶ " + header
+	}
+	details := "· " + ev.CreatedAt.Time().Format("2006-01-02 15:04")
+	if n := stats.reactions[ev.ID]; n > 0 {
+		details += fmt.Sprintf(" · ♥ %d", n)
+	}
+	if n := stats.reposts[ev.ID]; n > 0 {
+		details += fmt.Sprintf(" · ↻ %d", n)
+	}
+	fmt.Printf("%s%s %s\n", indent, successStyle.Render(header), infoStyle.Render(details))
+
+	body := ev.Content
+	if ev.Kind == nostr.KindArticle {
+		if tag := ev.Tags.Find("title"); tag != nil {
+			body = tag[1]
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		fmt.Println(indent + "  " + line)
+	}
+	fmt.Println(infoStyle.Render(indent + "  " + eventRef(ev)))
+	fmt.Println()
+
+	for _, child := range node.children {
+		showThreadNode(child, depth+1, target, stats, profiles)
+	}
+}
+
+// handleRead shows the conversation around a note: its parents up to the
+// thread's root and every reply, as an indented tree.
+func handleRead() {
+	if len(os.Args) < 3 {
+		fmt.Println(errorStyle.Render("Usage: nos read <note1|nevent1|naddr1>"))
+		os.Exit(exitUsage)
+	}
+	ptr, err := parseRef(os.Args[2])
+	if err != nil {
+		fail(exitUsage, err.Error())
+	}
+
+	fmt.Println(infoStyle.Render("Fetching note..."))
+	target, foundOn, err := fetchEvent(ptr)
+	if err != nil {
+		fail(exitFailed, err.Error())
+	}
+
+	// Hints from the reference come first, then wherever the note was
+	// found, then our own relays
+	var hints []string
+	switch p := ptr.(type) {
+	case nostr.EventPointer:
+		hints = p.Relays
+	case nostr.EntityPointer:
+		hints = p.Relays
+	}
+	relays := mergeRelays(hints, []string{foundOn}, getActiveRelays())
+
+	// Walk up the thread to its root
+	chain := []*nostr.Event{target}
+	for range maxThreadDepth {
+		ref, hint := threadParent(chain[0])
+		if ref == "" {
+			break
+		}
+		parentPtr, err := threadPointer(ref, mergeRelays([]string{hint}, relays))
+		if err != nil {
+			break
+		}
+		parent, _, err := fetchEvent(parentPtr)
+		if err != nil {
+			fmt.Println(infoStyle.Render("Some earlier notes in this thread couldn't be found."))
+			break
+		}
+		chain = append([]*nostr.Event{parent}, chain...)
+	}
+	root := chain[0]
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Fetching replies from %d relays...", len(relays))))
+	ids := []string{}
+	addresses := []string{}
+	for _, ev := range chain {
+		ids = append(ids, ev.ID)
+		if keys := threadKeys(ev); len(keys) > 1 {
+			addresses = append(addresses, keys[1])
+		}
+	}
+	events := map[string]*nostr.Event{}
+	for _, ev := range chain {
+		events[ev.ID] = ev
+	}
+	filters := []nostr.Filter{{Kinds: []int{nostr.KindTextNote}, Tags: nostr.TagMap{"e": ids}}}
+	if len(addresses) > 0 {
+		filters = append(filters, nostr.Filter{Kinds: []int{nostr.KindTextNote}, Tags: nostr.TagMap{"a": addresses}})
+	}
+	for _, filter := range filters {
+		for id, c := range collectEvents(relays, filter) {
+			events[id] = c.ev
+		}
+	}
+
+	// Build the tree; replies whose parent wasn't found hang off the root
+	nodes := map[string]*threadNode{}
+	sorted := []*nostr.Event{}
+	for _, ev := range events {
+		node := &threadNode{ev: ev}
+		for _, key := range threadKeys(ev) {
+			nodes[key] = node
+		}
+		sorted = append(sorted, ev)
+	}
+	slices.SortFunc(sorted, func(a, b *nostr.Event) int {
+		return int(a.CreatedAt - b.CreatedAt)
+	})
+	threadIDs := []string{}
+	for _, ev := range sorted {
+		threadIDs = append(threadIDs, ev.ID)
+		if ev.ID == root.ID {
+			continue
+		}
+		ref, _ := threadParent(ev)
+		parent, ok := nodes[ref]
+		if !ok || parent.ev.ID == ev.ID {
+			parent = nodes[root.ID]
+		}
+		parent.children = append(parent.children, nodes[ev.ID])
+	}
+
+	stats := collectThreadStats(relays, threadIDs)
+	profiles := getProfiles(feedAuthors(sorted))
+
+	fmt.Println()
+	showThreadNode(nodes[root.ID], 0, target.ID, stats, profiles)
+	fmt.Println(infoStyle.Render(fmt.Sprintf("%d notes in this thread.", len(events))))
+}
+
+// notificationKinds are the events that can tag us: notes (replies and
+// mentions), reactions, reposts and zap receipts.
+var notificationKinds = []int{nostr.KindTextNote, nostr.KindReaction, nostr.KindRepost, nostr.KindGenericRepost, nostr.KindZap}
+
+// notificationSections are the notification types in the order they are
+// shown, with their headings.
+var notificationSections = []struct {
+	kind  string
+	title string
+}{
+	{"reply", "Replies"},
+	{"mention", "Mentions"},
+	{"reaction", "Reactions"},
+	{"repost", "Reposts"},
+	{"zap", "Zaps"},
+}
+
+// notification is an event that tagged us, classified.
+type notification struct {
+	kind   string // "reply", "mention", "reaction", "repost" or "zap"
+	ev     *nostr.Event
+	from   string // who it's from; for zaps the sender, not the zap service
+	target string // the event it is about, empty for mentions
+	sats   int64
+}
+
+// notificationCursor is the local "last seen" state of nos notifications.
+// Events can reach relays late or share a second with ones already shown,
+// so checks look back notificationsOverlap before LastSeen and skip the
+// IDs in Seen.
+type notificationCursor struct {
+	LastSeen int64            `json:"last_seen"`
+	Seen     map[string]int64 `json:"seen,omitempty"` // ID to created_at, within the overlap
+}
+
+// since is where the next check for notifications starts.
+func (c *notificationCursor) since() nostr.Timestamp {
+	return nostr.Timestamp(c.LastSeen - int64(notificationsOverlap/time.Second))
+}
+
+// markSeen records that ev has been shown, forgetting IDs that have fallen
+// out of the overlap.
+func (c *notificationCursor) markSeen(ev *nostr.Event) {
+	if c.Seen == nil {
+		c.Seen = make(map[string]int64)
+	}
+	c.Seen[ev.ID] = int64(ev.CreatedAt)
+	c.LastSeen = max(c.LastSeen, int64(ev.CreatedAt))
+	for id, createdAt := range c.Seen {
+		if createdAt < int64(c.since()) {
+			delete(c.Seen, id)
+		}
+	}
+}
+
+// lastEventTag returns the value of the last e tag, which NIP-25 and NIP-57
+// use for the event being reacted to or zapped.
+func lastEventTag(tags nostr.Tags) string {
+	target := ""
+	for _, tag := range tags {
+		if len(tag) >= 2 && tag[0] == "e" {
+			target = tag[1]
+		}
+	}
+	return target
+}
+
+// classifyNotification works out what kind of notification ev is. Our own
+// events aren't notifications.
+func classifyNotification(ev *nostr.Event, self string) (notification, bool) {
+	n := notification{ev: ev, from: ev.PubKey}
+	switch ev.Kind {
+	case nostr.KindTextNote:
+		n.kind = "mention"
+		if parent, _ := threadParent(ev); parent != "" {
+			n.kind, n.target = "reply", parent
+		}
+	case nostr.KindReaction:
+		n.kind, n.target = "reaction", lastEventTag(ev.Tags)
+	case nostr.KindRepost, nostr.KindGenericRepost:
+		n.kind, n.target = "repost", lastEventTag(ev.Tags)
+	case nostr.KindZap:
+		n.kind, n.target = "zap", lastEventTag(ev.Tags)
+		n.from, n.sats = zapDetails(ev)
+	default:
+		return n, false
+	}
+	return n, n.from != self
+}
+
+// zapDetails returns who sent a zap and how many sats, read from the zap
+// request embedded in the receipt, falling back to the invoice amount.
+func zapDetails(receipt *nostr.Event) (string, int64) {
+	from := receipt.PubKey
+	var msats int64
+	if tag := receipt.Tags.Find("description"); tag != nil {
+		var request nostr.Event
+		if json.Unmarshal([]byte(tag[1]), &request) == nil {
+			from = request.PubKey
+			if amount := request.Tags.Find("amount"); amount != nil {
+				msats, _ = strconv.ParseInt(amount[1], 10, 64)
+			}
+		}
+	}
+	if msats == 0 {
+		if tag := receipt.Tags.Find("bolt11"); tag != nil {
+			msats = bolt11Msats(tag[1])
+		}
+	}
+	return from, msats / 1000
+}
+
+// bolt11Msats reads the amount of a BOLT11 invoice from its human-readable
+// part, e.g. "lnbc2500u1..." is 250000000 msats. It returns 0 when the
+// invoice has no amount.
+func bolt11Msats(invoice string) int64 {
+	m := bolt11AmountRegex.FindStringSubmatch(strings.ToLower(invoice))
+	if m == nil {
+		return 0
+	}
+	amount, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	// Amounts are in bitcoin, scaled by the multiplier. One that overflows
+	// an int64 is more bitcoin than will ever exist, so it isn't valid.
+	scale := int64(100_000_000_000)
+	switch m[2] {
+	case "m":
+		scale = 100_000_000
+	case "u":
+		scale = 100_000
+	case "n":
+		scale = 100
+	case "p":
+		// 1 pico-bitcoin is a tenth of a msat
+		return amount / 10
+	}
+	if amount > math.MaxInt64/scale {
+		return 0
+	}
+	return amount * scale
+}
+
+var bolt11AmountRegex = regexp.MustCompile(`^ln(?:bc|tb|bcrt|tbs)(\d+)([munp]?)1`)
+
+// reactionLabel is how a reaction is shown, "♥" for a plain like.
+func reactionLabel(content string) string {
+	switch content {
+	case "", "+":
+		return "♥"
+	case "-":
+		return "👎"
+	}
+	return truncate(content, 20)
+}
+
+// showNotifications prints notifications grouped by type and, within each
+// type, by the note they are about.
+func showNotifications(notes []notification, relays []string) {
+	targets := []string{}
+	authors := []string{}
+	for _, n := range notes {
+		if n.target != "" && !slices.Contains(targets, n.target) {
+			targets = append(targets, n.target)
+		}
+		authors = append(authors, n.from)
+	}
+	profiles := getProfiles(authors)
+
+	// Short excerpts of the notes people responded to
+	excerpts := map[string]string{}
+	if ids := slices.DeleteFunc(slices.Clone(targets), func(id string) bool { return !nostr.IsValid32ByteHex(id) }); len(ids) > 0 {
+		for id, c := range collectEvents(relays, nostr.Filter{IDs: ids}) {
+			excerpts[id] = fmt.Sprintf("%q", truncate(c.ev.Content, 50))
+		}
+	}
+	excerpt := func(target string) string {
+		if e, ok := excerpts[target]; ok {
+			return e
+		}
+		if ref, err := nip19.EncodeNote(target); err == nil {
+			return ref[:16] + "..."
+		}
+		return target
+	}
+
+	for _, section := range notificationSections {
+		groups := map[string][]notification{}
+		order := []string{}
+		for _, n := range notes {
+			if n.kind != section.kind {
+				continue
+			}
+			if _, ok := groups[n.target]; !ok {
+				order = append(order, n.target)
+			}
+			groups[n.target] = append(groups[n.target], n)
+		}
+		if len(order) == 0 {
+			continue
+		}
+
+		fmt.Println(titleStyle.Render(section.title))
+		for _, target := range order {
+			group := groups[target]
+			switch section.kind {
+			case "reply", "mention":
+				if target != "" {
+					fmt.Println(infoStyle.Render("On " + excerpt(target) + ":"))
+				}
+				for _, n := range group {
+					timestamp := n.ev.CreatedAt.Time().Format("2006-01-02 15:04")
+					fmt.Printf("  %s %s\n", successStyle.Render(displayName(n.from, profiles)), infoStyle.Render("· "+timestamp))
+					for _, line := range strings.Split(strings.TrimSpace(n.ev.Content), "\n") {
+						fmt.Println("    " + line)
+					}
+					ref, _ := nip19.EncodeNote(n.ev.ID)
+					fmt.Println(infoStyle.Render("    " + ref))
+				}
+			default:
+				who := []string{}
+				for _, n := range group {
+					switch section.kind {
+					case "reaction":
+						who = append(who, displayName(n.from, profiles)+" "+reactionLabel(n.ev.Content))
+					case "zap":
+						who = append(who, fmt.Sprintf("%s %d sats", displayName(n.from, profiles), n.sats))
+					default:
+						who = append(who, displayName(n.from, profiles))
+					}
+				}
+				fmt.Printf("  %s %s\n", infoStyle.Render(excerpt(target)+":"), strings.Join(who, ", "))
+			}
+		}
+		fmt.Println()
 	}
-	
-	fmt.Println(successStyle.Render("\n✓ Reset to default relays"))
-	fmt.Print("Press Enter to continue...")
-	fmt.Scanln()
 }
 
-func handleVerify() {
-	// Get stored key
-	nsec, err := getStoredKey()
-	if err != nil {
-		fmt.Println(errorStyle.Render("No stored key found. Please set up nos first."))
-		os.Exit(1)
+// handleNotifications shows what tagged us since we last looked, and with
+// --watch keeps printing new notifications as they arrive.
+func handleNotifications() {
+	var since string
+	all := false
+	watch := false
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--since" || args[i] == "-s") && i+1 < len(args):
+			since = args[i+1]
+			i++
+		case args[i] == "--all" || args[i] == "-a":
+			all = true
+		case args[i] == "--watch" || args[i] == "-w":
+			watch = true
+		default:
+			showNotificationsUsage()
+			os.Exit(exitUsage)
+		}
 	}
 
-	// Convert nsec to private key
-	var sk string
-	_, s, err := nip19.Decode(nsec)
+	sk, err := loadSecretKey()
 	if err != nil {
-		fmt.Println(errorStyle.Render("Error decoding key: " + err.Error()))
-		os.Exit(1)
+		fail(exitNoSetup, err.Error())
 	}
-	sk = s.(string)
-
-	// Get public key
 	pub, _ := nostr.GetPublicKey(sk)
-	npub, _ := nip19.EncodePublicKey(pub)
-	
-	fmt.Println(titleStyle.Render("Verifying Posts"))
-	fmt.Println(infoStyle.Render("Your npub: " + npub))
-	fmt.Println(infoStyle.Render("Checking relays for your recent posts..."))
-	fmt.Println()
 
-	// Get relay list
-	relays := getActiveRelays()
-	postsFound := 0
+	var cursor notificationCursor
+	loadData(notificationsFile, &cursor)
 
-	for _, url := range relays {
-		fmt.Printf("%s Checking %s... ", infoStyle.Render("→"), url)
-		
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		relay, err := nostr.RelayConnect(ctx, url)
+	// Only what's new since last time, unless asked otherwise
+	from := nostr.Timestamp(time.Now().Add(-notificationsSince).Unix())
+	switch {
+	case since != "":
+		from, err = parseSince(since)
 		if err != nil {
-			fmt.Println(errorStyle.Render("connection failed"))
-			cancel()
-			continue
+			fail(exitUsage, "invalid --since: "+err.Error())
 		}
+	case !all && cursor.LastSeen > 0:
+		from = cursor.since()
+	}
+	unseenOnly := since == "" && !all
 
-		// Create filter for user's posts
-		filter := nostr.Filter{
-			Authors: []string{pub},
-			Kinds:   []int{nostr.KindTextNote},
-			Limit:   5,
-		}
+	relays := getActiveRelays()
+	filter := nostr.Filter{Kinds: notificationKinds, Tags: nostr.TagMap{"p": []string{pub}}, Since: &from}
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Checking %d relays for notifications...", len(relays))))
+	fmt.Println()
 
-		// Subscribe to events
-		sub, err := relay.Subscribe(ctx, []nostr.Filter{filter})
-		if err != nil {
-			fmt.Println(errorStyle.Render("subscription failed"))
-			relay.Close()
-			cancel()
+	notes := []notification{}
+	for _, c := range collectEvents(relays, filter) {
+		if _, ok := cursor.Seen[c.ev.ID]; ok && unseenOnly {
 			continue
 		}
+		if n, ok := classifyNotification(c.ev, pub); ok {
+			notes = append(notes, n)
+		}
+	}
+	slices.SortFunc(notes, func(a, b notification) int {
+		return int(a.ev.CreatedAt - b.ev.CreatedAt)
+	})
 
-		// Collect events with timeout
-		events := make([]nostr.Event, 0)
-		timeout := time.After(3 * time.Second)
-		
-	loop:
-		for {
-			select {
-			case ev := <-sub.Events:
-				if ev != nil {
-					events = append(events, *ev)
-				}
-			case <-timeout:
-				break loop
-			}
+	if len(notes) == 0 {
+		fmt.Println(infoStyle.Render("No new notifications."))
+	} else {
+		showNotifications(notes, relays)
+		for _, n := range notes {
+			cursor.markSeen(n.ev)
+		}
+		if err := saveData(notificationsFile, cursor); err != nil {
+			fmt.Println(errorStyle.Render("Error saving notifications cursor: " + err.Error()))
 		}
+	}
+	if !watch {
+		return
+	}
 
-		sub.Close()
-		relay.Close()
-		cancel()
+	fmt.Println(infoStyle.Render("Watching for new notifications. Press Ctrl+C to stop."))
+	fmt.Println()
 
-		if len(events) > 0 {
-			fmt.Println(successStyle.Render(fmt.Sprintf("✓ found %d posts", len(events))))
-			postsFound += len(events)
-			
-			// Show recent posts from this relay
-			for i, ev := range events {
-				if i >= 3 { // Only show first 3
-					break
-				}
-				timestamp := time.Unix(ev.CreatedAt.Time().Unix(), 0).Format("2006-01-02 15:04:05")
-				content := ev.Content
-				if len(content) > 50 {
-					content = content[:50] + "..."
-				}
-				fmt.Printf("    %s [%s] %s\n", infoStyle.Render("•"), timestamp, content)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	seen := make(map[string]bool)
+	for _, n := range notes {
+		seen[n.ev.ID] = true
+	}
+	now := nostr.Now()
+	live := nostr.Filters{{Kinds: notificationKinds, Tags: nostr.TagMap{"p": []string{pub}}, Since: &now}}
+
+	incoming := make(chan *nostr.Event)
+	for _, url := range relays {
+		go tailRelay(ctx, url, live, incoming)
+	}
+	for {
+		select {
+		case ev := <-incoming:
+			if seen[ev.ID] || !validFeedEvent(ev, live) {
+				continue
 			}
-		} else {
-			fmt.Println(infoStyle.Render("no posts found"))
+			seen[ev.ID] = true
+			n, ok := classifyNotification(ev, pub)
+			if !ok {
+				continue
+			}
+			showNotifications([]notification{n}, relays)
+			cursor.markSeen(ev)
+			saveData(notificationsFile, cursor)
+		case <-ctx.Done():
+			return
 		}
 	}
+}
 
-	fmt.Println()
-	if postsFound == 0 {
-		fmt.Println(errorStyle.Render("No posts found on any relay."))
-		fmt.Println(infoStyle.Render("This could mean:"))
-		fmt.Println(infoStyle.Render("  - Your posts haven't propagated yet (wait a few seconds)"))
-		fmt.Println(infoStyle.Render("  - The relays rejected your posts"))
-		fmt.Println(infoStyle.Render("  - There's an issue with your key"))
-	} else {
-	fmt.Println(successStyle.Render(fmt.Sprintf("Total posts found: %d", postsFound)))
-	}
+func showNotificationsUsage() {
+	fmt.Println(titleStyle.Render("Notifications"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos notifications [--since <time>] [--all] [--watch]"))
+	fmt.Println(infoStyle.Render("\nOptions:"))
+	fmt.Println(infoStyle.Render("  -s, --since <7d|12h|date>  - Show notifications since then"))
+	fmt.Println(infoStyle.Render("  -a, --all                  - Include ones you've already seen (last 7 days)"))
+	fmt.Println(infoStyle.Render("  -w, --watch                - Keep printing new notifications as they arrive"))
 }
 
-func interactiveSetup() {
-	fmt.Println()
-	fmt.Println(titleStyle.Render("Setup Account"))
-	fmt.Println(infoStyle.Render("Let's set up your Nostr account."))
-	fmt.Println()
+// getSearchRelay returns the relay nos search always asks, if it supports
+// NIP-50.
+func getSearchRelay() string {
+	url, err := keyring.Get(appName, searchRelayKey)
+	if err != nil || url == "" {
+		return defaultSearchRelay
+	}
+	return url
+}
 
-	nsec, err := promptForKey()
-	if err != nil {
-		fmt.Println(errorStyle.Render("\nSetup cancelled."))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+// handleSearchRelay shows or changes the search relay: "nos relay search
+// <url>" sets it and "nos relay search default" goes back to the default.
+func handleSearchRelay(args []string) {
+	switch {
+	case len(args) == 0:
+		fmt.Println(infoStyle.Render("nos search uses " + getSearchRelay() + " and any relay in your list that supports NIP-50."))
+	case args[0] == "default":
+		err := keyring.Delete(appName, searchRelayKey)
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			fail(exitFailed, "deleting search relay: "+err.Error())
+		}
+		fmt.Println(successStyle.Render("✓ Search relay reset to " + defaultSearchRelay))
+	default:
+		url := args[0]
+		if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
+			fail(exitUsage, "relay URL must start with wss:// or ws://")
+		}
+		if info, ok := getRelayInfo([]string{url})[url]; ok && !supportsNIP(info, 50) {
+			fmt.Println(infoStyle.Render("Note: " + url + " doesn't list NIP-50 support, so nos search will skip it until it does."))
+		}
+		if err := keyring.Set(appName, searchRelayKey, url); err != nil {
+			fail(exitFailed, "storing search relay: "+err.Error())
+		}
+		fmt.Println(successStyle.Render("✓ Search relay set to " + url))
 	}
 
-	// Store the key
-	err = storeKey(nsec)
-	if err != nil {
-		fmt.Println(errorStyle.Render("\nError storing key: " + err.Error()))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+	if jsonMode {
+		printJSON(searchRelayReport{SearchRelay: getSearchRelay()})
 	}
+}
 
-	// Show npub
-	var sk string
-	_, s, _ := nip19.Decode(nsec)
-	sk = s.(string)
-	pub, _ := nostr.GetPublicKey(sk)
-	npub, _ := nip19.EncodePublicKey(pub)
+// searchRelayReport is the --json result of nos relay search.
+type searchRelayReport struct {
+	SearchRelay string `json:"search_relay"`
+}
 
-	fmt.Println(successStyle.Render("\n✓ Account setup complete!"))
-	fmt.Println(infoStyle.Render("Your npub: " + npub))
-	fmt.Print("\nPress Enter to continue...")
-	fmt.Scanln()
+// supportsNIP reports whether a relay's NIP-11 document lists NIP n.
+// Relays list them as numbers, though some use strings.
+func supportsNIP(info nip11.RelayInformationDocument, n int) bool {
+	for _, nip := range info.SupportedNIPs {
+		switch v := nip.(type) {
+		case float64:
+			if int(v) == n {
+				return true
+			}
+		case int:
+			if v == n {
+				return true
+			}
+		case string:
+			if v == strconv.Itoa(n) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func interactivePost() {
-	fmt.Println()
-	fmt.Println(titleStyle.Render("Post to Nostr"))
-	
-	var message string
-	form := huh.NewForm(
-		huh.NewGroup(
-			huh.NewText().
-				Title("What would you like to post?").
-				Description("Your message will be posted to Nostr").
-				Placeholder("Hello Nostr!").
-				Value(&message).
-				Validate(func(str string) error {
-					if strings.TrimSpace(str) == "" {
-						return fmt.Errorf("message cannot be empty")
-					}
-					return nil
-				}),
-		),
-	)
+// searchReport is the --json result of nos search.
+type searchReport struct {
+	Query   string        `json:"query"`
+	Found   int           `json:"found"`
+	Relays  []verifyRelay `json:"relays"`
+	Results []verifyPost  `json:"results"`
+}
 
-	err := form.Run()
-	if err != nil {
-		fmt.Println(infoStyle.Render("\nPost cancelled."))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+// handleSearch runs a NIP-50 full-text search on the search relay and every
+// active relay that supports it, and shows the merged results newest first.
+func handleSearch() {
+	var words []string
+	var kinds []int
+	var authors []string
+	var since string
+	limit := defaultFeedLimit
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--kind" || args[i] == "-k") && i+1 < len(args):
+			kind, err := strconv.Atoi(args[i+1])
+			if err != nil || kind < 0 || kind > 65535 {
+				fail(exitUsage, "--kind must be a number between 0 and 65535")
+			}
+			kinds = append(kinds, kind)
+			i++
+		case (args[i] == "--author" || args[i] == "-a") && i+1 < len(args):
+			pub, _, err := parsePubkeyRef(args[i+1])
+			if err != nil {
+				fail(exitUsage, err.Error())
+			}
+			authors = append(authors, pub)
+			i++
+		case (args[i] == "--since" || args[i] == "-s") && i+1 < len(args):
+			since = args[i+1]
+			i++
+		case (args[i] == "--limit" || args[i] == "-n") && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fail(exitUsage, "--limit must be a positive number")
+			}
+			limit = n
+			i++
+		case strings.HasPrefix(args[i], "-"):
+			showSearchUsage()
+			os.Exit(exitUsage)
+		default:
+			words = append(words, args[i])
+		}
 	}
 
-	// Get stored key
-	nsec, err := getStoredKey()
-	if err != nil {
-		fmt.Println(errorStyle.Render("\nError: No stored key found."))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+	query := strings.TrimSpace(strings.Join(words, " "))
+	if query == "" {
+		showSearchUsage()
+		os.Exit(exitUsage)
+	}
+	if len(kinds) == 0 {
+		kinds = []int{nostr.KindTextNote}
 	}
 
-	// Convert nsec to private key
-	var sk string
-	_, s, err := nip19.Decode(nsec)
-	if err != nil {
-		fmt.Println(errorStyle.Render("\nError decoding key: " + err.Error()))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+	filter := nostr.Filter{Search: query, Kinds: kinds, Authors: authors, Limit: limit}
+	if since != "" {
+		ts, err := parseSince(since)
+		if err != nil {
+			fail(exitUsage, "invalid --since: "+err.Error())
+		}
+		filter.Since = &ts
 	}
-	sk = s.(string)
 
-	fmt.Println()
-	// Post to Nostr
-	err = postToNostr(sk, message)
-	if err != nil {
-		fmt.Println(errorStyle.Render("\nError posting: " + err.Error()))
-	} else {
-		fmt.Println(successStyle.Render("\n✓ Posted successfully!"))
+	// Relays that don't support NIP-50 would ignore the search and return
+	// everything else matching the filter
+	candidates := mergeRelays([]string{getSearchRelay()}, getActiveRelays())
+	infos := getRelayInfo(candidates)
+	relays := slices.DeleteFunc(candidates, func(url string) bool {
+		return !supportsNIP(infos[url], 50)
+	})
+	if len(relays) == 0 {
+		fail(exitFailed, "none of your relays support search (NIP-50), set one with 'nos relay search <url>'")
 	}
-	
-	fmt.Print("\nPress Enter to continue...")
-	fmt.Scanln()
-}
 
-func interactiveReset() {
-	fmt.Println()
-	fmt.Println(titleStyle.Render("Reset Account"))
-	fmt.Println(errorStyle.Render("⚠️  This will delete your stored nsec key and relay configuration!"))
-	
-	var confirm bool
-	form := huh.NewForm(
-		huh.NewGroup(
-			huh.NewConfirm().
-				Title("Are you sure you want to reset all data?").
-				Affirmative("Yes, reset everything").
-				Negative("No, cancel").
-				Value(&confirm),
-		),
-	)
+	fmt.Println(titleStyle.Render("Searching for " + strconv.Quote(query)))
 
-	err := form.Run()
-	if err != nil || !confirm {
-		fmt.Println(infoStyle.Render("\nReset cancelled."))
-		fmt.Print("Press Enter to continue...")
-		fmt.Scanln()
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	type searchResult struct {
+		url     string
+		events  []*nostr.Event
+		err     error
+		elapsed time.Duration
+	}
+	answers := make(chan searchResult, len(relays))
+	for _, url := range relays {
+		go func() {
+			start := time.Now()
+			events, err := queryRelay(ctx, url, filter)
+			answers <- searchResult{url, events, err, time.Since(start)}
+		}()
 	}
 
-	// Delete nsec key
-	err = keyring.Delete(appName, keyringUser)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
-		fmt.Println(errorStyle.Render("\nError deleting key: " + err.Error()))
+	// Report in relay list order, whichever answered first
+	results := map[string]searchResult{}
+	for range relays {
+		res := <-answers
+		results[res.url] = res
 	}
 
-	// Delete relay list
-	err = keyring.Delete(appName, relayListKey)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
-		fmt.Println(errorStyle.Render("\nError deleting relay list: " + err.Error()))
+	report := searchReport{Query: query, Relays: []verifyRelay{}, Results: []verifyPost{}}
+	events := []*nostr.Event{}
+	seen := make(map[string]bool)
+	unreachable := 0
+	for _, url := range relays {
+		res := results[url]
+		relay := verifyRelay{URL: url, Status: "empty", ElapsedMs: res.elapsed.Milliseconds()}
+		fmt.Printf("%s %s... ", infoStyle.Render("→"), url)
+
+		var re *relayError
+		switch {
+		case len(res.events) == 0 && errors.As(res.err, &re):
+			fmt.Println(errorStyle.Render(explainRelayError(re)))
+			relay.Status, relay.Error = "failed", re.Error()
+			unreachable++
+		case len(res.events) == 0 && res.err != nil:
+			fmt.Println(errorStyle.Render("connection failed"))
+			relay.Status, relay.Error = "failed", res.err.Error()
+			unreachable++
+		case len(res.events) == 0:
+			fmt.Println(infoStyle.Render("no results"))
+		default:
+			fmt.Println(successStyle.Render(fmt.Sprintf("✓ found %d results", len(res.events))))
+			relay.Status = "found"
+		}
+		report.Relays = append(report.Relays, relay)
+
+		for _, ev := range res.events {
+			// Matches doesn't know about the search term itself
+			if seen[ev.ID] || !validFeedEvent(ev, nostr.Filters{{Kinds: filter.Kinds, Authors: filter.Authors, Since: filter.Since}}) {
+				continue
+			}
+			seen[ev.ID] = true
+			events = append(events, ev)
+		}
 	}
 
-	fmt.Println(successStyle.Render("\n✓ All data has been reset!"))
-	fmt.Println(infoStyle.Render("You can now set up nos with a different account."))
-	fmt.Print("\nPress Enter to continue...")
-	fmt.Scanln()
+	slices.SortFunc(events, func(a, b *nostr.Event) int {
+		return int(b.CreatedAt - a.CreatedAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	fmt.Println()
+	profiles := getProfiles(feedAuthors(events))
+	for _, ev := range events {
+		timestamp := ev.CreatedAt.Time().Format("2006-01-02 15:04:05")
+		fmt.Printf("    %s [%s] %s: %s\n", infoStyle.Render("•"), timestamp, displayName(ev.PubKey, profiles), truncate(ev.Content, 80))
+		fmt.Println(infoStyle.Render("      " + eventRef(ev)))
+		report.Results = append(report.Results, verifyPost{ID: ev.ID, Pubkey: ev.PubKey, CreatedAt: int64(ev.CreatedAt), Content: ev.Content})
+	}
+	if len(events) == 0 {
+		fmt.Println(infoStyle.Render("Nothing found."))
+	} else {
+		fmt.Println(successStyle.Render(fmt.Sprintf("Total results: %d", len(events))))
+	}
+
+	report.Found = len(events)
+	if jsonMode {
+		printJSON(report)
+	}
+	switch {
+	case unreachable == len(relays):
+		os.Exit(exitFailed)
+	case unreachable > 0:
+		os.Exit(exitPartial)
+	}
+}
+
+func showSearchUsage() {
+	fmt.Println(titleStyle.Render("Search"))
+	fmt.Println(infoStyle.Render("Usage:"))
+	fmt.Println(infoStyle.Render("  nos search \"<query>\" [--kind <n>] [--author <npub>] [--since <time>] [--limit <n>]"))
+	fmt.Println(infoStyle.Render("\nOptions:"))
+	fmt.Println(infoStyle.Render("  -k, --kind <n>             - Search this event kind instead of notes (repeatable)"))
+	fmt.Println(infoStyle.Render("  -a, --author <npub>        - Only results by this author (repeatable)"))
+	fmt.Println(infoStyle.Render("  -s, --since <30d|12h|date> - Only results since then"))
+	fmt.Println(infoStyle.Render(fmt.Sprintf("  -n, --limit <n>            - Show at most n results (default %d)", defaultFeedLimit)))
+	fmt.Println(infoStyle.Render("\nOnly relays that support NIP-50 are searched; see 'nos relay search'."))
 }
@@ -0,0 +1,901 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/zalando/go-keyring"
+)
+
+// gradient is a deterministic test image.
+func gradient(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / (w - 1)), uint8(y * 255 / (h - 1)), uint8((x + y) * 7 % 256), 255})
+		}
+	}
+	return img
+}
+
+// writePNG saves img to a temporary file and returns its path and contents.
+func writePNG(t *testing.T, img image.Image) (string, []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "photo.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// authEvent decodes and checks the event in an "Authorization: Nostr" header.
+func authEvent(t *testing.T, header string) nostr.Event {
+	t.Helper()
+	encoded, ok := strings.CutPrefix(header, "Nostr ")
+	if !ok {
+		t.Fatalf("Authorization = %q, want a Nostr token", header)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decoding auth header: %v", err)
+	}
+	var ev nostr.Event
+	if err := json.Unmarshal(data, &ev); err != nil {
+		t.Fatalf("decoding auth event: %v", err)
+	}
+	if ok, _ := ev.CheckSignature(); !ok {
+		t.Fatal("auth event has an invalid signature")
+	}
+	return ev
+}
+
+func TestBlurhash(t *testing.T) {
+	// Reference values from github.com/buckket/go-blurhash
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{"black", image.NewRGBA(image.Rect(0, 0, 8, 8)), "L00000fQfQfQfQfQfQfQfQfQfQfQ"},
+		{"landscape", gradient(32, 24), "L$Hewg2nwsX5l|W7jwe@gGfifUff"},
+		{"portrait", gradient(16, 32), "T$Hxvn2;wul?W8jvgEfofRnoWTjq"},
+	}
+	for _, tt := range tests {
+		if got := blurhash(tt.img); got != tt.want {
+			t.Errorf("blurhash(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUploadBlossom(t *testing.T) {
+	path, data := writePNG(t, gradient(32, 24))
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/upload" {
+			t.Errorf("got %s %s, want PUT /upload", r.Method, r.URL.Path)
+		}
+		auth := authEvent(t, r.Header.Get("Authorization"))
+		if auth.Kind != 24242 || auth.PubKey != pub {
+			t.Errorf("auth event kind %d by %s, want kind 24242 by %s", auth.Kind, auth.PubKey, pub)
+		}
+		if auth.Tags.FindWithValue("t", "upload") == nil || auth.Tags.FindWithValue("x", hash) == nil {
+			t.Errorf("auth event tags %v lack t=upload and x=%s", auth.Tags, hash)
+		}
+		if auth.Tags.Find("expiration") == nil {
+			t.Error("auth event has no expiration")
+		}
+		if got := r.Header.Get("X-SHA-256"); got != hash {
+			t.Errorf("X-SHA-256 = %q, want %q", got, hash)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != string(data) {
+			t.Error("uploaded body differs from the file")
+		}
+		json.NewEncoder(w).Encode(blobDescriptor{URL: "https://cdn.example.com/" + hash + ".png", SHA256: hash, Size: int64(len(body)), Type: "image/png"})
+	}))
+	defer srv.Close()
+
+	file, err := uploadMedia(sk, mediaServer{URL: srv.URL, Type: "blossom"}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := nostr.Tag{
+		"imeta",
+		"url https://cdn.example.com/" + hash + ".png",
+		"m image/png",
+		"x " + hash,
+		"dim 32x24",
+		"blurhash L$Hewg2nwsX5l|W7jwe@gGfifUff",
+	}
+	if got := file.imeta(); !slices.Equal(got, want) {
+		t.Errorf("imeta = %v, want %v", got, want)
+	}
+}
+
+func TestUploadNIP96(t *testing.T) {
+	path, data := writePNG(t, gradient(32, 24))
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	sk := nostr.GeneratePrivateKey()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/nostr/nip96.json":
+			w.Write([]byte(`{"api_url": "` + srv.URL + `/api/upload"}`))
+		case "/api/upload":
+			if r.Method != http.MethodPost {
+				t.Errorf("upload method = %s, want POST", r.Method)
+			}
+			auth := authEvent(t, r.Header.Get("Authorization"))
+			if auth.Kind != 27235 || auth.Tags.FindWithValue("u", srv.URL+"/api/upload") == nil || auth.Tags.FindWithValue("payload", hash) == nil {
+				t.Errorf("auth event kind %d tags %v, want kind 27235 for the upload URL and payload", auth.Kind, auth.Tags)
+			}
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("reading form file: %v", err)
+				return
+			}
+			body, _ := io.ReadAll(file)
+			if header.Filename != "photo.png" || string(body) != string(data) {
+				t.Errorf("uploaded %s differs from the file", header.Filename)
+			}
+			// Pretend the server needs to process the file first
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"status": "processing", "processing_url": "` + srv.URL + `/api/status"}`))
+		case "/api/status":
+			w.Write([]byte(`{"status": "success", "nip94_event": {"tags": [["url", "https://cdn.example.com/photo.webp"], ["m", "image/webp"], ["x", "` + hash + `"], ["dim", "16x12"]]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	file, err := uploadMedia(sk, mediaServer{URL: srv.URL, Type: "nip96"}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// What the server reports about the transformed file wins
+	want := nostr.Tag{
+		"imeta",
+		"url https://cdn.example.com/photo.webp",
+		"m image/webp",
+		"x " + hash,
+		"dim 16x12",
+		"blurhash L$Hewg2nwsX5l|W7jwe@gGfifUff",
+	}
+	if got := file.imeta(); !slices.Equal(got, want) {
+		t.Errorf("imeta = %v, want %v", got, want)
+	}
+}
+
+func TestNIP96APIURLDelegation(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"api_url": "https://media.example.com/upload"}`))
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"delegated_to_url": "` + target.URL + `"}`))
+	}))
+	defer origin.Close()
+
+	got, err := nip96APIURL(context.Background(), origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://media.example.com/upload" {
+		t.Errorf("nip96APIURL = %q, want the delegated server's api_url", got)
+	}
+
+	notNIP96 := httptest.NewServer(http.NotFoundHandler())
+	defer notNIP96.Close()
+	if _, err := nip96APIURL(context.Background(), notNIP96.URL); err == nil {
+		t.Error("nip96APIURL succeeded for a server without nip96.json")
+	}
+}
+
+func TestPostNIP96HonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := postNIP96(ctx, srv.URL, nostr.GeneratePrivateKey(), "photo.png", []byte("data"), "image/png")
+	if err == nil {
+		t.Fatal("postNIP96 succeeded against a server that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("postNIP96 took %s, the context deadline was ignored", elapsed)
+	}
+}
+
+func TestPowRequirement(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{newRelayError("pow: difficulty 12 is less than 20"), 20},
+		{newRelayError("pow: needs 28 bits"), 28},
+		{newRelayError("pow: not enough work"), 0},
+		{newRelayError("pow: 300 is not a real difficulty, 16 is"), 16},
+		{newRelayError("blocked: difficulty 20"), 0},
+		{errors.New("pow: 20"), 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := powRequirement(tt.err); got != tt.want {
+			t.Errorf("powRequirement(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGetRelayInfoCachesFailures(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	for range 3 {
+		if infos := getRelayInfo([]string{url}); len(infos) != 0 {
+			t.Fatalf("getRelayInfo = %v, want nothing for a relay without NIP-11", infos)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("relay was asked for its NIP-11 document %d times, want 1", requests)
+	}
+}
+
+func TestBuildReplyTags(t *testing.T) {
+	id := func(c string) string { return strings.Repeat(c, 64) }
+	pubkey := func() string {
+		pub, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+		return pub
+	}
+	self, alice, bob := pubkey(), pubkey(), pubkey()
+	tests := []struct {
+		name   string
+		parent nostr.Tags
+		want   nostr.Tags
+	}{
+		{
+			name:   "top-level note",
+			parent: nostr.Tags{},
+			want: nostr.Tags{
+				{"e", id("1"), "wss://relay.example.com", "root", alice},
+				{"p", alice},
+			},
+		},
+		{
+			name:   "marked reply",
+			parent: nostr.Tags{{"e", id("0"), "wss://root.example.com", "root", bob}, {"e", id("9"), "", "reply"}, {"p", bob}, {"p", self}},
+			want: nostr.Tags{
+				{"e", id("0"), "wss://root.example.com", "root", bob},
+				{"e", id("1"), "wss://relay.example.com", "reply", alice},
+				{"p", alice},
+				{"p", bob},
+			},
+		},
+		{
+			name:   "unmarked reply",
+			parent: nostr.Tags{{"e", id("0")}, {"e", id("9")}},
+			want: nostr.Tags{
+				{"e", id("0"), "", "root"},
+				{"e", id("1"), "wss://relay.example.com", "reply", alice},
+				{"p", alice},
+			},
+		},
+		{
+			name:   "mentions aren't the root",
+			parent: nostr.Tags{{"e", id("7"), "", "mention"}, {"e", id("0"), "", "reply"}},
+			want: nostr.Tags{
+				{"e", id("0"), "", "root"},
+				{"e", id("1"), "wss://relay.example.com", "reply", alice},
+				{"p", alice},
+			},
+		},
+		{
+			name:   "only mentions",
+			parent: nostr.Tags{{"e", id("7"), "", "mention"}, {"q", id("8")}},
+			want: nostr.Tags{
+				{"e", id("1"), "wss://relay.example.com", "root", alice},
+				{"p", alice},
+			},
+		},
+		{
+			name:   "invalid root",
+			parent: nostr.Tags{{"e", "", "", "root"}, {"e", "nonsense"}},
+			want: nostr.Tags{
+				{"e", id("1"), "wss://relay.example.com", "root", alice},
+				{"p", alice},
+			},
+		},
+	}
+	for _, tt := range tests {
+		parent := &nostr.Event{ID: id("1"), PubKey: alice, Tags: tt.parent}
+		got := buildReplyTags(parent, "wss://relay.example.com", self)
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: buildReplyTags = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBolt11Msats(t *testing.T) {
+	tests := []struct {
+		invoice string
+		want    int64
+	}{
+		{"lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqf", 250_000_000},
+		{"lnbc20m1pvjluezpp5qqqsyqcyq5rqwzqf", 2_000_000_000},
+		{"lnbc10n1pjqqqqq", 1_000},
+		{"lnbc10p1pjqqqqq", 1},
+		{"lnbc9678785340p1pwmna7lpp5gc3xfm08u9qy06djf8dfflhugl6p7lgza6dsjxq454gxhj9t7a0s", 967_878_534},
+		{"LNBC1500N1PJQQQQQ", 150_000},
+		{"lntb1u1pjqqqqq", 100_000},
+		{"lnbc21pjqqqqq", 200_000_000_000},
+		{"lnbc1pvjluezpp5qqqsyqcyq5rqwzqf", 0},
+		{"lnbc99999999999999999p1pjqqqqq", 9_999_999_999_999_999},
+		{"lnbc92233720368m1pjqqqqq", 9_223_372_036_800_000_000},
+		{"lnbc92233720369m1pjqqqqq", 0},
+		{"lnbc92233720368547u1pjqqqqq", 9_223_372_036_854_700_000},
+		{"lnbc92233720368548u1pjqqqqq", 0},
+		{"lnbc100000000000000001pjqqqqq", 0},
+		{"not an invoice", 0},
+	}
+	for _, tt := range tests {
+		if got := bolt11Msats(tt.invoice); got != tt.want {
+			t.Errorf("bolt11Msats(%q) = %d, want %d", tt.invoice, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationCursor(t *testing.T) {
+	overlap := nostr.Timestamp(notificationsOverlap / time.Second)
+	cursor := notificationCursor{}
+	cursor.markSeen(&nostr.Event{ID: "old", CreatedAt: 1000})
+	cursor.markSeen(&nostr.Event{ID: "same-second", CreatedAt: 1000 + overlap})
+	cursor.markSeen(&nostr.Event{ID: "late", CreatedAt: 1001})
+	cursor.markSeen(&nostr.Event{ID: "new", CreatedAt: 1000 + overlap})
+
+	if cursor.LastSeen != int64(1000+overlap) {
+		t.Errorf("LastSeen = %d, want %d", cursor.LastSeen, 1000+overlap)
+	}
+	if cursor.since() != 1000 {
+		t.Errorf("since = %d, want the overlap before LastSeen", cursor.since())
+	}
+	for _, id := range []string{"same-second", "late", "new"} {
+		if _, ok := cursor.Seen[id]; !ok {
+			t.Errorf("%s was forgotten while still inside the overlap", id)
+		}
+	}
+	cursor.markSeen(&nostr.Event{ID: "newer", CreatedAt: 1002 + overlap})
+	if _, ok := cursor.Seen["late"]; ok {
+		t.Error("late is still remembered after falling out of the overlap")
+	}
+}
+
+func TestQuorumShortfall(t *testing.T) {
+	refused := newRelayError("blocked: not on the allow list")
+	results := []relayResult{
+		{url: "wss://a.example.com"},
+		{url: "wss://b.example.com/"},
+		{url: "wss://c.example.com", err: refused},
+	}
+	tests := []struct {
+		name string
+		opts postOptions
+		want string
+	}{
+		{"no quorum", postOptions{}, ""},
+		{"enough relays", postOptions{minRelays: 2}, ""},
+		{"too few relays", postOptions{minRelays: 3}, "2 relays accepted it, 3 needed"},
+		{"required relay accepted", postOptions{require: []string{"wss://b.example.com"}}, ""},
+		{"required relay refused", postOptions{require: []string{"wss://c.example.com"}}, "wss://c.example.com didn't accept it"},
+		{"required relay missing", postOptions{require: []string{"wss://d.example.com"}}, "wss://d.example.com didn't accept it"},
+		{
+			"everything wrong",
+			postOptions{minRelays: 3, require: []string{"wss://c.example.com", "wss://d.example.com"}},
+			"2 relays accepted it, 3 needed; wss://c.example.com didn't accept it; wss://d.example.com didn't accept it",
+		},
+	}
+	for _, tt := range tests {
+		if got := quorumShortfall(results, tt.opts); got != tt.want {
+			t.Errorf("%s: quorumShortfall = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublishExitCode(t *testing.T) {
+	ok := relayResult{url: "wss://a.example.com"}
+	failed := relayResult{url: "wss://b.example.com", err: newRelayError("error: database down")}
+	quorum := postOptions{minRelays: 1}
+	tests := []struct {
+		name    string
+		results []relayResult
+		err     error
+		opts    postOptions
+		want    int
+	}{
+		{"all accepted", []relayResult{ok}, nil, postOptions{}, exitOK},
+		{"some accepted", []relayResult{ok, failed}, nil, postOptions{}, exitPartial},
+		{"none accepted", []relayResult{failed}, errNoRelayAccepted, postOptions{}, exitFailed},
+		{"no relays", nil, nil, postOptions{}, exitFailed},
+		{"quorum met", []relayResult{ok, failed}, nil, quorum, exitOK},
+		{"quorum missed", []relayResult{ok, failed}, nil, postOptions{minRelays: 2}, exitPartial},
+		{"none accepted with a quorum", []relayResult{failed}, nil, postOptions{require: []string{"wss://c.example.com"}}, exitFailed},
+	}
+	for _, tt := range tests {
+		if got := publishExitCode(tt.results, tt.err, tt.opts); got != tt.want {
+			t.Errorf("%s: publishExitCode = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCombineExitCodes(t *testing.T) {
+	tests := []struct {
+		codes []int
+		want  int
+	}{
+		{nil, exitOK},
+		{[]int{exitOK, exitOK}, exitOK},
+		{[]int{exitFailed, exitFailed}, exitFailed},
+		{[]int{exitOK, exitFailed}, exitPartial},
+		{[]int{exitPartial, exitOK}, exitPartial},
+	}
+	for _, tt := range tests {
+		if got := combineExitCodes(tt.codes); got != tt.want {
+			t.Errorf("combineExitCodes(%v) = %d, want %d", tt.codes, got, tt.want)
+		}
+	}
+}
+
+func TestParseArticle(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    articleFrontmatter
+		body    string
+		wantErr bool
+	}{
+		{
+			name: "frontmatter",
+			data: "---\ntitle: Hello\ntags: [nostr, go]\nslug: hello\n---\n\n# Hello\n\nWorld\n",
+			want: articleFrontmatter{Title: "Hello", Tags: []string{"nostr", "go"}, Slug: "hello"},
+			body: "# Hello\n\nWorld",
+		},
+		{
+			name: "no frontmatter",
+			data: "# Hello\n\n---\n\nWorld\n",
+			body: "# Hello\n\n---\n\nWorld",
+		},
+		{
+			name: "empty frontmatter",
+			data: "---\n---\nBody\n",
+			body: "Body",
+		},
+		{
+			name: "windows line endings",
+			data: "---\r\ntitle: Hello\r\n---\r\nBody\r\n",
+			want: articleFrontmatter{Title: "Hello"},
+			body: "Body",
+		},
+		{
+			name: "closing line at the end",
+			data: "---\ntitle: Hello\n---",
+			want: articleFrontmatter{Title: "Hello"},
+		},
+		{
+			name: "lookalike lines in the frontmatter",
+			data: "---\ntitle: Hello\nsummary: |\n  ----\n  ---not the end\n---\nBody\n",
+			want: articleFrontmatter{Title: "Hello", Summary: "----\n---not the end\n"},
+			body: "Body",
+		},
+		{
+			name: "rules in the body",
+			data: "---\ntitle: Hello\n---\nOne\n\n---\n\nTwo\n",
+			want: articleFrontmatter{Title: "Hello"},
+			body: "One\n\n---\n\nTwo",
+		},
+		{
+			name:    "unclosed",
+			data:    "---\ntitle: Hello\n----\nBody\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			data:    "---\ntitle: [Hello\n---\nBody\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		meta, body, err := parseArticle(tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: parseArticle succeeded, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseArticle: %v", tt.name, err)
+			continue
+		}
+		if meta.Title != tt.want.Title || meta.Summary != tt.want.Summary || meta.Slug != tt.want.Slug || !slices.Equal(meta.Tags, tt.want.Tags) {
+			t.Errorf("%s: frontmatter = %+v, want %+v", tt.name, meta, tt.want)
+		}
+		if body != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.body)
+		}
+	}
+}
+
+func TestAuthorFilters(t *testing.T) {
+	authors := make([]string, 2*feedAuthorsPerFilter+1)
+	for i := range authors {
+		authors[i] = strconv.Itoa(i)
+	}
+	since := nostr.Timestamp(1000)
+	filters := authorFilters(nostr.Filter{Kinds: feedKinds, Since: &since, Limit: 20}, authors)
+
+	if len(filters) != 3 {
+		t.Fatalf("got %d filters, want 3", len(filters))
+	}
+	got := []string{}
+	for _, f := range filters {
+		if len(f.Authors) > feedAuthorsPerFilter {
+			t.Errorf("filter lists %d authors, want at most %d", len(f.Authors), feedAuthorsPerFilter)
+		}
+		if !slices.Equal(f.Kinds, feedKinds) || f.Since != &since || f.Limit != 20 {
+			t.Errorf("filter %v lost the kinds, since or limit", f)
+		}
+		got = append(got, f.Authors...)
+	}
+	if !slices.Equal(got, authors) {
+		t.Error("authors were lost or reordered across the filters")
+	}
+	if filters := authorFilters(nostr.Filter{}, nil); len(filters) != 0 {
+		t.Errorf("got %d filters for no authors, want none", len(filters))
+	}
+}
+
+func TestSupportsNIP(t *testing.T) {
+	tests := []struct {
+		nips []any
+		want bool
+	}{
+		{[]any{float64(1), float64(11), float64(50)}, true},
+		{[]any{1, 50}, true},
+		{[]any{"1", "50"}, true},
+		{[]any{float64(1), float64(11)}, false},
+		{[]any{"NIP-50", float64(500)}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		info := nip11.RelayInformationDocument{SupportedNIPs: tt.nips}
+		if got := supportsNIP(info, 50); got != tt.want {
+			t.Errorf("supportsNIP(%v, 50) = %v, want %v", tt.nips, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRelayJSON(t *testing.T) {
+	keyring.MockInit()
+	var out bytes.Buffer
+	jsonMode, jsonOutput = true, &out
+	defer func() { jsonMode, jsonOutput = false, os.Stdout }()
+
+	keyring.Set(appName, searchRelayKey, "wss://search.example.com")
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "wss://search.example.com"},
+		{[]string{"default"}, defaultSearchRelay},
+	} {
+		out.Reset()
+		handleSearchRelay(tt.args)
+		var report map[string]string
+		if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+			t.Fatalf("nos relay search %v printed %q, want JSON: %v", tt.args, out.String(), err)
+		}
+		if report["search_relay"] != tt.want {
+			t.Errorf("nos relay search %v: search_relay = %q, want %q", tt.args, report["search_relay"], tt.want)
+		}
+	}
+}
+
+func TestExtractContentTags(t *testing.T) {
+	keyring.MockInit()
+	keyring.Set(appName, relayListKey, `["wss://mine.example.com", "wss://other.example.com"]`)
+
+	pub, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	id := strings.Repeat("e", 64)
+	npub, _ := nip19.EncodePublicKey(pub)
+	nprofile, _ := nip19.EncodeProfile(pub, []string{"wss://profile.example.com"})
+	note, _ := nip19.EncodeNote(id)
+	nevent, _ := nip19.EncodeEvent(id, []string{"wss://event.example.com"}, pub)
+	neventBare, _ := nip19.EncodeEvent(id, nil, "")
+	naddr, _ := nip19.EncodeEntity(pub, nostr.KindArticle, "hello", []string{"wss://article.example.com"})
+
+	tests := []struct {
+		name    string
+		content string
+		want    nostr.Tags
+	}{
+		{"plain", "just words", nostr.Tags{}},
+		{"hashtags", "#Nostr and #go, not a#b or #123 or &#39;", nostr.Tags{{"t", "nostr"}, {"t", "go"}}},
+		{"links", "see https://example.com/a_(b). and (https://example.com/c)", nostr.Tags{{"r", "https://example.com/a_(b)"}, {"r", "https://example.com/c"}}},
+		{"hashtag in a link", "https://example.com/#anchor #real", nostr.Tags{{"r", "https://example.com/#anchor"}, {"t", "real"}}},
+		{"npub", "hi nostr:" + npub, nostr.Tags{{"p", pub, "wss://mine.example.com"}}},
+		{"nprofile", "hi nostr:" + nprofile, nostr.Tags{{"p", pub, "wss://profile.example.com"}}},
+		{"note", "nostr:" + note, nostr.Tags{{"q", id, "wss://mine.example.com"}}},
+		{"nevent", "nostr:" + nevent, nostr.Tags{{"p", pub, "wss://event.example.com"}, {"q", id, "wss://event.example.com", pub}}},
+		{"nevent without hints", "nostr:" + neventBare, nostr.Tags{{"q", id, "wss://mine.example.com"}}},
+		{"naddr", "nostr:" + naddr, nostr.Tags{{"a", "30023:" + pub + ":hello", "wss://article.example.com"}, {"p", pub, "wss://article.example.com"}}},
+		{"duplicates", "nostr:" + npub + " nostr:" + nprofile + " #a #A", nostr.Tags{{"p", pub, "wss://mine.example.com"}, {"t", "a"}}},
+		{"not a reference", "nostr:npub1invalid #ok", nostr.Tags{{"t", "ok"}}},
+	}
+	for _, tt := range tests {
+		got := extractContentTags(tt.content)
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: extractContentTags = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestComposeInEditor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh and cp")
+	}
+	src := filepath.Join(t.TempDir(), "my post.md")
+	if err := os.WriteFile(src, []byte("Hello #world\n\n"+editorInstructions), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// An editor with arguments and a quoted path, like EDITOR="code --wait"
+	t.Setenv("VISUAL", "cp '"+src+"'")
+
+	got, err := composeInEditor("draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello #world" {
+		t.Errorf("composeInEditor = %q, want the text above the scissors line", got)
+	}
+}
+
+func TestPublishes(t *testing.T) {
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{"nos", true},
+		{"nos hello world", true},
+		{"nos --dry-run hello", false},
+		{"nos reply note1abc thanks", true},
+		{"nos event -k 1 -c hi --dry-run", false},
+		{"nos feed", false},
+		{"nos schedule list", false},
+		{"nos schedule cancel abc", false},
+		{"nos schedule 1h hello", false},
+		{"nos schedule run", true},
+		{"nos drafts", false},
+		{"nos drafts rm 1", false},
+		{"nos drafts post 1", true},
+		{"nos article publish post.md", true},
+		{"nos media show", false},
+	}
+	for _, tt := range tests {
+		if got := publishes(strings.Fields(tt.args)); got != tt.want {
+			t.Errorf("publishes(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParsePostArgsDryRunAttach(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	photo := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(photo, []byte("jpeg"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := parsePostArgs([]string{"--attach", photo, "hello"}); err != nil {
+		t.Fatalf("--attach alone: %v", err)
+	}
+	// A dry run must not upload anything
+	if _, _, err := parsePostArgs([]string{"--dry-run", "--attach", photo, "hello"}); err == nil {
+		t.Error("--dry-run with --attach was accepted")
+	}
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2025, 3, 10, 14, 30, 0, 0, loc)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2h", want: now.Add(2 * time.Hour)},
+		{value: "in 30m", want: now.Add(30 * time.Minute)},
+		{value: "+1h30m", want: now.Add(90 * time.Minute)},
+		{value: "3d", want: time.Date(2025, 3, 13, 14, 30, 0, 0, loc)},
+		{value: " in 1d ", want: time.Date(2025, 3, 11, 14, 30, 0, 0, loc)},
+		{value: "18:00", want: time.Date(2025, 3, 10, 18, 0, 0, 0, loc)},
+		{value: "09:30", want: time.Date(2025, 3, 11, 9, 30, 0, 0, loc)},
+		{value: "14:30", want: time.Date(2025, 3, 11, 14, 30, 0, 0, loc)},
+		{value: "2025-04-01 09:30", want: time.Date(2025, 4, 1, 9, 30, 0, 0, loc)},
+		{value: "2025-04-01 09:30:15", want: time.Date(2025, 4, 1, 9, 30, 15, 0, loc)},
+		{value: "2025-04-01T09:30", want: time.Date(2025, 4, 1, 9, 30, 0, 0, loc)},
+		{value: "2025-04-01", want: time.Date(2025, 4, 1, 0, 0, 0, 0, loc)},
+		{value: "2025-04-01T09:30:00Z", want: time.Date(2025, 4, 1, 9, 30, 0, 0, time.UTC)},
+		{value: "tomorrow", wantErr: true},
+		{value: "25:00", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseScheduleTime(tt.value, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseScheduleTime(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseScheduleTime(%q): %v", tt.value, err)
+		} else if !got.Equal(tt.want) {
+			t.Errorf("parseScheduleTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitThread(t *testing.T) {
+	link := "https://example.com/" + strings.Repeat("a", 30)
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		numbered bool
+		want     []string
+	}{
+		{"short", "Hello world", 280, false, []string{"Hello world"}},
+		{"separators", "One\n---\nTwo\r\n  ---  \r\nThree\n---\n---\n", 280, false, []string{"One", "Two", "Three"}},
+		{"not a separator", "One\n----\nTwo ---", 280, false, []string{"One\n----\nTwo ---"}},
+		{"word boundaries", "aaa bbb ccc ddd", 8, false, []string{"aaa bbb", "ccc ddd"}},
+		{"line breaks kept", "aaa\nbbb ccc", 8, false, []string{"aaa\nbbb", "ccc"}},
+		{"long word cut", "abcdefghij", 4, false, []string{"abcd", "efgh", "ij"}},
+		{"links stay whole", "see " + link + " ok", 20, false, []string{"see", link, "ok"}},
+		{"multibyte", "ééé ééé", 3, false, []string{"ééé", "ééé"}},
+		{"numbered", "One\n---\nTwo", 280, true, []string{"One\n\n1/2", "Two\n\n2/2"}},
+		{"numbered leaves room", "aaa bbb ccc", 11, true, []string{"aaa\n\n1/3", "bbb\n\n2/3", "ccc\n\n3/3"}},
+		{"empty", " \n---\n ", 280, false, nil},
+	}
+	for _, tt := range tests {
+		got := splitThread(tt.text, tt.maxChars, tt.numbered)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: splitThread = %q, want %q", tt.name, got, tt.want)
+		}
+		for _, note := range got {
+			if n := len([]rune(note)); n > tt.maxChars && !strings.Contains(note, link) {
+				t.Errorf("%s: note %q is %d characters, over %d", tt.name, note, n, tt.maxChars)
+			}
+		}
+	}
+}
+
+func TestParseTagArg(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    nostr.Tag
+		wantErr bool
+	}{
+		{arg: "t=nostr", want: nostr.Tag{"t", "nostr"}},
+		{arg: "e=abc;wss://relay.example.com;reply", want: nostr.Tag{"e", "abc", "wss://relay.example.com", "reply"}},
+		{arg: "d=", want: nostr.Tag{"d", ""}},
+		{arg: "alt=a=b", want: nostr.Tag{"alt", "a=b"}},
+		{arg: "e=abc;;mention", want: nostr.Tag{"e", "abc", "", "mention"}},
+		{arg: `alt=a\;b;c`, want: nostr.Tag{"alt", "a;b", "c"}},
+		{arg: `alt=a\\;b`, want: nostr.Tag{"alt", `a\`, "b"}},
+		{arg: `alt=C:\path`, want: nostr.Tag{"alt", `C:\path`}},
+		{arg: "-", want: nostr.Tag{"-"}},
+		{arg: "=value", wantErr: true},
+		{arg: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTagArg(tt.arg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTagArg(%q) = %v, want an error", tt.arg, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTagArg(%q): %v", tt.arg, err)
+		} else if !slices.Equal(got, tt.want) {
+			t.Errorf("parseTagArg(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestNewRelayError(t *testing.T) {
+	tests := []struct {
+		reason  string
+		prefix  string
+		message string
+		retry   bool
+	}{
+		{"blocked: you are banned", reasonBlocked, "you are banned", false},
+		{"pow: difficulty 20 required", reasonPow, "difficulty 20 required", false},
+		{"invalid: bad signature", reasonInvalid, "bad signature", false},
+		{"restricted: paid relay", reasonRestricted, "paid relay", false},
+		{"rate-limited: slow down", reasonRateLimited, "slow down", true},
+		{"auth-required: log in first", reasonAuthRequired, "log in first", true},
+		{"error: database down", reasonError, "database down", true},
+		{"duplicate:", reasonDuplicate, "", true},
+		{"something went wrong", "", "something went wrong", true},
+		{"note: time is 12:30", "note", "time is 12:30", true},
+		{"try again at 12:30", "", "try again at 12:30", true},
+		{": empty prefix", "", ": empty prefix", true},
+		{"", "", "", true},
+	}
+	for _, tt := range tests {
+		err := newRelayError(tt.reason)
+		if err.prefix != tt.prefix || err.message != tt.message {
+			t.Errorf("newRelayError(%q) = {%q, %q}, want {%q, %q}", tt.reason, err.prefix, err.message, tt.prefix, tt.message)
+		}
+		if got := shouldRetry(err); got != tt.retry {
+			t.Errorf("shouldRetry(%q) = %v, want %v", tt.reason, got, tt.retry)
+		}
+	}
+
+	// Timeouts and connection problems are always worth another try
+	for _, err := range []error{context.DeadlineExceeded, errors.New("connection refused"), classifyPublishError(errors.New("msg: blocked: spam"))} {
+		want := relayPrefix(err) != reasonBlocked
+		if got := shouldRetry(err); got != want {
+			t.Errorf("shouldRetry(%v) = %v, want %v", err, got, want)
+		}
+	}
+}